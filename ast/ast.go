@@ -62,6 +62,15 @@ func (il IntegerLiteral) expressionNode()      {}
 func (il IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il IntegerLiteral) String() string       { return il.Token.Literal }
 
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
@@ -229,6 +238,21 @@ type CallExpression struct {
 	Arguments []Expression
 }
 
+func (c *CallExpression) expressionNode()      {}
+func (c *CallExpression) TokenLiteral() string { return c.Token.Literal }
+func (c *CallExpression) String() string {
+	var out bytes.Buffer
+	var args []string
+	for _, arg := range c.Arguments {
+		args = append(args, arg.String())
+	}
+	out.WriteString(c.Function.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+	return out.String()
+}
+
 type ForStatement struct {
 	Token     token.Token
 	Condition Expression
@@ -260,3 +284,45 @@ func (a *AssignmentStatement) String() string {
 	out.WriteString(a.Ident.Value + " = " + a.Value.String())
 	return out.String()
 }
+
+// Line returns the source line of the token node was parsed from, such as
+// the operator of an infix expression, or 0 if it is not known.
+func Line(node Node) int {
+	switch n := node.(type) {
+	case *Program:
+		if len(n.Statements) > 0 {
+			return Line(n.Statements[0])
+		}
+	case *Identifier:
+		return n.Token.Line
+	case *IntegerLiteral:
+		return n.Token.Line
+	case *StringLiteral:
+		return n.Token.Line
+	case *FunctionLiteral:
+		return n.Token.Line
+	case *Boolean:
+		return n.Token.Line
+	case *PrefixExpression:
+		return n.Token.Line
+	case *InfixExpression:
+		return n.Token.Line
+	case *LetStatement:
+		return n.Token.Line
+	case *ReturnStatement:
+		return n.Token.Line
+	case *ExpressionStatement:
+		return n.Token.Line
+	case *IfExpression:
+		return n.Token.Line
+	case *BlockStatement:
+		return n.Token.Line
+	case *CallExpression:
+		return n.Token.Line
+	case *ForStatement:
+		return n.Token.Line
+	case *AssignmentStatement:
+		return n.Token.Line
+	}
+	return 0
+}
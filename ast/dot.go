@@ -0,0 +1,130 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Dot renders the tree rooted at node as a Graphviz DOT digraph.
+// Every vertex is labelled with the node type and, where present,
+// its operator and token literal; edges are labelled with the field
+// that links parent and child (left, right, condition, ...).
+func Dot(node Node) string {
+	d := &dotWriter{}
+	d.out.WriteString("digraph AST {\n")
+	d.out.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	d.node(node)
+	d.out.WriteString("}\n")
+	return d.out.String()
+}
+
+type dotWriter struct {
+	out  bytes.Buffer
+	next int
+}
+
+// node writes the vertex for n and its subtree, returning the vertex id.
+func (d *dotWriter) node(n Node) string {
+	id := fmt.Sprintf("n%d", d.next)
+	d.next++
+
+	lines, children := describe(n)
+	fmt.Fprintf(&d.out, "\t%s [label=%q];\n", id, strings.Join(lines, "\n"))
+	for _, child := range children {
+		to := d.node(child.node)
+		fmt.Fprintf(&d.out, "\t%s -> %s [label=%q];\n", id, to, child.label)
+	}
+	return id
+}
+
+type child struct {
+	label string
+	node  Node
+}
+
+// describe returns the label lines shown for n, its type followed by its
+// operator or literal, and its non-nil children.
+func describe(n Node) ([]string, []child) {
+	var lines []string
+	var children []child
+	switch n := n.(type) {
+	case *Program:
+		lines = []string{"Program"}
+		for i, s := range n.Statements {
+			children = append(children, child{fmt.Sprintf("%d", i), s})
+		}
+	case *LetStatement:
+		lines = []string{"LetStatement", "name: " + n.Name.Value}
+		children = []child{{"value", n.Value}}
+	case *ReturnStatement:
+		lines = []string{"ReturnStatement"}
+		children = []child{{"value", n.ReturnValue}}
+	case *ExpressionStatement:
+		lines = []string{"ExpressionStatement"}
+		children = []child{{"expression", n.Expression}}
+	case *AssignmentStatement:
+		lines = []string{"AssignmentStatement", "name: " + n.Ident.Value}
+		children = []child{{"value", n.Value}}
+	case *ForStatement:
+		lines = []string{"ForStatement"}
+		children = []child{{"condition", n.Condition}, {"block", n.Block}}
+	case *BlockStatement:
+		lines = []string{"BlockStatement"}
+		for i, s := range n.Statements {
+			children = append(children, child{fmt.Sprintf("%d", i), s})
+		}
+	case *Identifier:
+		lines = []string{"Identifier", "literal: " + n.Value}
+	case *IntegerLiteral:
+		lines = []string{"IntegerLiteral", "literal: " + n.TokenLiteral()}
+	case *Boolean:
+		lines = []string{"Boolean", "literal: " + n.TokenLiteral()}
+	case *StringLiteral:
+		lines = []string{"StringLiteral", "literal: " + strconv.Quote(n.Value)}
+	case *PrefixExpression:
+		lines = []string{"PrefixExpression", "operator: " + n.Operator}
+		children = []child{{"right", n.Right}}
+	case *InfixExpression:
+		lines = []string{"InfixExpression", "operator: " + n.Operator}
+		children = []child{{"left", n.Left}, {"right", n.Right}}
+	case *IfExpression:
+		lines = []string{"IfExpression"}
+		children = []child{{"condition", n.Condition}, {"consequence", n.Consequence}, {"alternative", n.Alternative}}
+	case *FunctionLiteral:
+		var params []string
+		for _, p := range n.Parameters {
+			params = append(params, p.Value)
+		}
+		lines = []string{"FunctionLiteral", "params: " + strings.Join(params, ", ")}
+		children = []child{{"body", n.Body}}
+	case *CallExpression:
+		lines = []string{"CallExpression"}
+		children = []child{{"function", n.Function}}
+		for i, arg := range n.Arguments {
+			children = append(children, child{fmt.Sprintf("arg %d", i), arg})
+		}
+	default:
+		lines = []string{fmt.Sprintf("%T", n)}
+	}
+
+	nonNil := children[:0]
+	for _, c := range children {
+		if !isNilNode(c.node) {
+			nonNil = append(nonNil, c)
+		}
+	}
+	return lines, nonNil
+}
+
+// isNilNode reports whether n is nil or a typed nil pointer, which the
+// parser leaves behind for nodes it failed to parse.
+func isNilNode(n Node) bool {
+	if n == nil {
+		return true
+	}
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
@@ -0,0 +1,64 @@
+package ast
+
+import (
+	"interpreter/token"
+	"strings"
+	"testing"
+)
+
+func TestDot(t *testing.T) {
+	// 1 + 2 * 3
+	program := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{
+				Token: token.Token{Type: token.INT, Literal: "1"},
+				Expression: &InfixExpression{
+					Token:    token.Token{Type: token.PLUS, Literal: "+"},
+					Operator: "+",
+					Left:     &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					Right: &InfixExpression{
+						Token:    token.Token{Type: token.ASTERISK, Literal: "*"},
+						Operator: "*",
+						Left:     &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+						Right:    &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "3"}, Value: 3},
+					},
+				},
+			},
+		},
+	}
+
+	dot := Dot(program)
+	expected := []string{
+		"digraph AST {",
+		`n0 [label="Program"];`,
+		`n1 [label="ExpressionStatement"];`,
+		`n2 [label="InfixExpression\noperator: +"];`,
+		`n3 [label="IntegerLiteral\nliteral: 1"];`,
+		`n4 [label="InfixExpression\noperator: *"];`,
+		`n2 -> n3 [label="left"];`,
+		`n2 -> n4 [label="right"];`,
+		`n4 -> n6 [label="right"];`,
+		`n0 -> n1 [label="0"];`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(dot, line) {
+			t.Errorf("Dot output does not contain %q, got\n%s", line, dot)
+		}
+	}
+	if !strings.HasSuffix(dot, "}\n") {
+		t.Errorf("Dot output is not terminated, got\n%s", dot)
+	}
+}
+
+func TestDotSkipsNilNodes(t *testing.T) {
+	var value *Identifier
+	program := &Program{
+		Statements: []Statement{
+			&ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return"}, ReturnValue: value},
+		},
+	}
+	dot := Dot(program)
+	if strings.Count(dot, "->") != 1 {
+		t.Errorf("Expected a single edge for a return without value, got\n%s", dot)
+	}
+}
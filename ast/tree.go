@@ -0,0 +1,30 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Tree renders the tree rooted at node as indented text, one node per
+// line, prefixed by the field linking it to its parent.
+func Tree(node Node) string {
+	var out bytes.Buffer
+	writeTree(&out, node, "", 0)
+	return out.String()
+}
+
+func writeTree(out *bytes.Buffer, n Node, label string, depth int) {
+	lines, children := describe(n)
+	out.WriteString(strings.Repeat("  ", depth))
+	if label != "" {
+		out.WriteString(label + ": ")
+	}
+	out.WriteString(lines[0])
+	if len(lines) > 1 {
+		out.WriteString(" (" + strings.Join(lines[1:], ", ") + ")")
+	}
+	out.WriteString("\n")
+	for _, c := range children {
+		writeTree(out, c.node, c.label, depth+1)
+	}
+}
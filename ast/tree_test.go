@@ -0,0 +1,48 @@
+package ast
+
+import (
+	"interpreter/token"
+	"testing"
+)
+
+func TestTree(t *testing.T) {
+	// let a = -b;
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name:  &Identifier{Token: token.Token{Type: token.IDENT, Literal: "a"}, Value: "a"},
+				Value: &PrefixExpression{
+					Token:    token.Token{Type: token.MINUS, Literal: "-"},
+					Operator: "-",
+					Right:    &Identifier{Token: token.Token{Type: token.IDENT, Literal: "b"}, Value: "b"},
+				},
+			},
+		},
+	}
+	expected := `Program
+  0: LetStatement (name: a)
+    value: PrefixExpression (operator: -)
+      right: Identifier (literal: b)
+`
+	if tree := Tree(program); tree != expected {
+		t.Errorf("Tree expected\n%s\ngot\n%s", expected, tree)
+	}
+}
+
+func TestTreeCallExpression(t *testing.T) {
+	call := &CallExpression{
+		Token:    token.Token{Type: token.LPAREN, Literal: "("},
+		Function: &Identifier{Token: token.Token{Type: token.IDENT, Literal: "f"}, Value: "f"},
+		Arguments: []Expression{
+			&Boolean{Token: token.Token{Type: token.TRUE, Literal: "true"}, Value: true},
+		},
+	}
+	expected := `CallExpression
+  function: Identifier (literal: f)
+  arg 0: Boolean (literal: true)
+`
+	if tree := Tree(call); tree != expected {
+		t.Errorf("Tree expected\n%s\ngot\n%s", expected, tree)
+	}
+}
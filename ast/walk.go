@@ -0,0 +1,52 @@
+package ast
+
+// Inspect traverses the tree rooted at node depth-first, calling f for
+// each node. If f returns false, the children of that node are skipped.
+// Nil children are not visited.
+func Inspect(node Node, f func(Node) bool) {
+	if isNilNode(node) || !f(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *Program:
+		for _, stmt := range n.Statements {
+			Inspect(stmt, f)
+		}
+	case *BlockStatement:
+		for _, stmt := range n.Statements {
+			Inspect(stmt, f)
+		}
+	case *LetStatement:
+		Inspect(n.Name, f)
+		Inspect(n.Value, f)
+	case *ReturnStatement:
+		Inspect(n.ReturnValue, f)
+	case *ExpressionStatement:
+		Inspect(n.Expression, f)
+	case *AssignmentStatement:
+		Inspect(n.Ident, f)
+		Inspect(n.Value, f)
+	case *ForStatement:
+		Inspect(n.Condition, f)
+		Inspect(n.Block, f)
+	case *PrefixExpression:
+		Inspect(n.Right, f)
+	case *InfixExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *IfExpression:
+		Inspect(n.Condition, f)
+		Inspect(n.Consequence, f)
+		Inspect(n.Alternative, f)
+	case *FunctionLiteral:
+		for _, param := range n.Parameters {
+			Inspect(param, f)
+		}
+		Inspect(n.Body, f)
+	case *CallExpression:
+		Inspect(n.Function, f)
+		for _, arg := range n.Arguments {
+			Inspect(arg, f)
+		}
+	}
+}
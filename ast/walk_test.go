@@ -0,0 +1,51 @@
+package ast
+
+import (
+	"interpreter/token"
+	"reflect"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	ident := func(name string) *Identifier {
+		return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+	// let f = fn(x) { x + y }; f(z)
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: ident("f"),
+				Value: &FunctionLiteral{
+					Parameters: []*Identifier{ident("x")},
+					Body: &BlockStatement{Statements: []Statement{
+						&ExpressionStatement{Expression: &InfixExpression{Left: ident("x"), Operator: "+", Right: ident("y")}},
+					}},
+				},
+			},
+			&ExpressionStatement{Expression: &CallExpression{Function: ident("f"), Arguments: []Expression{ident("z")}}},
+		},
+	}
+
+	var names []string
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok {
+			names = append(names, ident.Value)
+		}
+		return true
+	})
+	if expected := []string{"f", "x", "x", "y", "f", "z"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("visited %v, want %v", names, expected)
+	}
+
+	names = nil
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok {
+			names = append(names, ident.Value)
+		}
+		_, isFunction := node.(*FunctionLiteral)
+		return !isFunction
+	})
+	if expected := []string{"f", "f", "z"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("visited %v when skipping functions, want %v", names, expected)
+	}
+}
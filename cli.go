@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"interpreter/ast"
+	"interpreter/compiler"
+	"interpreter/format"
+	"interpreter/interpreter"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"interpreter/repl"
+	"interpreter/token"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+func (c *cli) flags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	return flags
+}
+
+// read returns the content of path, or of stdin when path is empty.
+func (c *cli) read(path string) (string, bool) {
+	var src []byte
+	var err error
+	if path == "" {
+		src, err = io.ReadAll(c.stdin)
+	} else {
+		src, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(c.stderr, "monkey: %v\n", err)
+		return "", false
+	}
+	return string(src), true
+}
+
+// parse parses src, reporting errors prefixed with name.
+func (c *cli) parse(name, src string) (*ast.Program, bool) {
+	if name == "" {
+		name = "<stdin>"
+	}
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	for _, msg := range p.Errors() {
+		fmt.Fprintf(c.stderr, "%s: %s\n", name, msg)
+	}
+	return program, len(p.Errors()) == 0
+}
+
+func (c *cli) run(args []string) int {
+	flags := c.flags("run")
+	grants := grantFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return EXIT_USAGE
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(c.stderr, "usage: monkey run [--allow-...] <file> [args...]")
+		return EXIT_USAGE
+	}
+	src, ok := c.read(flags.Arg(0))
+	if !ok {
+		return EXIT_IO_ERROR
+	}
+	return c.execute(flags.Arg(0), src, flags.Args()[1:], *grants...)
+}
+
+// grantFlags defines the flags granting capabilities to scripts on flags
+// and returns the grants they collect.
+func grantFlags(flags *flag.FlagSet) *[]string {
+	grants := &[]string{}
+	scoped := func(capability string) func(string) error {
+		return func(scope string) error {
+			if scope == "" {
+				return errors.New("empty scope")
+			}
+			*grants = append(*grants, capability+":"+scope)
+			return nil
+		}
+	}
+	whole := func(capability string) func(string) error {
+		return func(string) error {
+			*grants = append(*grants, capability)
+			return nil
+		}
+	}
+	flags.Func("allow", "grant a `capability`, such as net:example.com (repeatable)", func(spec string) error {
+		*grants = append(*grants, spec)
+		return nil
+	})
+	flags.Func("allow-read", "allow reading files below `dir` (repeatable)", scoped(interpreter.CAP_FS_READ))
+	flags.Func("allow-write", "allow writing files below `dir` (repeatable)", scoped(interpreter.CAP_FS_WRITE))
+	flags.BoolFunc("allow-net", "allow network access", whole(interpreter.CAP_NET))
+	flags.BoolFunc("allow-env", "allow reading environment variables", whole(interpreter.CAP_ENV))
+	flags.BoolFunc("allow-clock", "allow reading the clock", whole(interpreter.CAP_CLOCK))
+	flags.BoolFunc("allow-exec", "allow running commands", whole(interpreter.CAP_EXEC))
+	return grants
+}
+
+// script runs all of stdin as a single program, which is what happens
+// when monkey is invoked without arguments on a pipe or redirected file.
+func (c *cli) script() int {
+	src, ok := c.read("")
+	if !ok {
+		return EXIT_IO_ERROR
+	}
+	return c.execute("<stdin>", src, nil)
+}
+
+func (c *cli) eval(args []string) int {
+	flags := c.flags("eval")
+	expr := flags.String("e", "", "expression to evaluate")
+	grants := grantFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return EXIT_USAGE
+	}
+	if *expr == "" {
+		fmt.Fprintln(c.stderr, "usage: monkey eval [--allow-...] -e <expr> [args...]")
+		return EXIT_USAGE
+	}
+	return c.execute("-e", *expr, flags.Args(), *grants...)
+}
+
+// execute evaluates src, or runs it if it is compiled bytecode, with args
+// bound to the args array and the capabilities in grants, and prints the
+// resulting value, if any.
+func (c *cli) execute(name, src string, args []string, grants ...string) int {
+	in := interpreter.New(interpreter.WithStdout(c.stdout), interpreter.WithStderr(c.stderr))
+	if err := in.Grant(grants...); err != nil {
+		fmt.Fprintf(c.stderr, "monkey: %v\n", err)
+		return EXIT_USAGE
+	}
+	in.Set("args", scriptArgs(args))
+	var result object.Object
+	var err error
+	if compiler.IsBytecode([]byte(src)) {
+		bytecode, ok := c.decode(name, src)
+		if !ok {
+			return EXIT_PARSE_ERROR
+		}
+		result, err = in.RunBytecode(context.Background(), name, bytecode)
+	} else {
+		result, err = in.Run(context.Background(), name, src)
+	}
+	var parseErr *interpreter.ParseError
+	switch {
+	case errors.As(err, &parseErr):
+		for _, msg := range parseErr.Errors {
+			fmt.Fprintf(c.stderr, "%s: %s\n", name, msg)
+		}
+		return EXIT_PARSE_ERROR
+	case err != nil:
+		fmt.Fprintln(c.stderr, err)
+		return EXIT_RUNTIME_ERROR
+	}
+	if result != object.NULL {
+		fmt.Fprintln(c.stdout, result.Inspect())
+	}
+	return EXIT_OK
+}
+
+// decode decodes the bytecode file data, reporting errors prefixed with
+// name.
+func (c *cli) decode(name, data string) (*compiler.Bytecode, bool) {
+	var bytecode compiler.Bytecode
+	if err := bytecode.UnmarshalBinary([]byte(data)); err != nil {
+		fmt.Fprintf(c.stderr, "%s: %v\n", name, err)
+		return nil, false
+	}
+	return &bytecode, true
+}
+
+// compile compiles the script src, reporting errors prefixed with name.
+func (c *cli) compile(name, src string) (*compiler.Bytecode, bool) {
+	program, ok := c.parse(name, src)
+	if !ok {
+		return nil, false
+	}
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		fmt.Fprintf(c.stderr, "%s: %v\n", name, err)
+		return nil, false
+	}
+	bytecode := comp.Bytecode()
+	if name != "" {
+		bytecode.Source = filepath.Base(name)
+	}
+	return bytecode, true
+}
+
+func (c *cli) build(args []string) int {
+	flags := c.flags("build")
+	output := flags.String("o", "", "write the bytecode to `file`, by default the script's name with the extension .mkc")
+	if err := flags.Parse(args); err != nil {
+		return EXIT_USAGE
+	}
+	path, rest := flags.Arg(0), flags.Args()
+	// Flags may also follow the script, as in `monkey build file.mk -o file.mkc`.
+	if len(rest) > 1 {
+		if err := flags.Parse(rest[1:]); err != nil {
+			return EXIT_USAGE
+		}
+		rest = flags.Args()
+	} else {
+		rest = nil
+	}
+	if path == "" || len(rest) > 0 {
+		fmt.Fprintln(c.stderr, "usage: monkey build <file> [-o output]")
+		return EXIT_USAGE
+	}
+	src, ok := c.read(path)
+	if !ok {
+		return EXIT_IO_ERROR
+	}
+	bytecode, ok := c.compile(path, src)
+	if !ok {
+		return EXIT_PARSE_ERROR
+	}
+	data, err := bytecode.MarshalBinary()
+	if err != nil {
+		fmt.Fprintf(c.stderr, "%s: %v\n", path, err)
+		return EXIT_PARSE_ERROR
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(path, filepath.Ext(path)) + ".mkc"
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		fmt.Fprintf(c.stderr, "monkey: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+	return EXIT_OK
+}
+
+// disasm prints the instructions of a script or bytecode file annotated
+// with the source lines they come from. The source of a bytecode file is
+// looked for next to it.
+func (c *cli) disasm(args []string) int {
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	}
+	src, ok := c.read(path)
+	if !ok {
+		return EXIT_IO_ERROR
+	}
+	var bytecode *compiler.Bytecode
+	var source []string
+	if compiler.IsBytecode([]byte(src)) {
+		if bytecode, ok = c.decode(path, src); !ok {
+			return EXIT_PARSE_ERROR
+		}
+		if bytecode.Source != "" && path != "" {
+			if text, err := os.ReadFile(filepath.Join(filepath.Dir(path), bytecode.Source)); err == nil {
+				source = strings.Split(string(text), "\n")
+			}
+		}
+	} else {
+		if bytecode, ok = c.compile(path, src); !ok {
+			return EXIT_PARSE_ERROR
+		}
+		source = strings.Split(src, "\n")
+	}
+
+	fmt.Fprintf(c.stdout, "main:\n%s", bytecode.Instructions.Disassemble(bytecode.Lines, source))
+	for i, constant := range bytecode.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(c.stdout, "\nfunction %d (%s), locals %s:\n%s", i,
+			strings.Join(fn.Locals[:fn.NumParameters], ", "), strings.Join(fn.Locals, ", "),
+			fn.Instructions.Disassemble(fn.Lines, source))
+	}
+	var constants []string
+	for i, constant := range bytecode.Constants {
+		if _, ok := constant.(*object.CompiledFunction); !ok {
+			constants = append(constants, fmt.Sprintf("%d %s", i, constant.Inspect()))
+		}
+	}
+	if len(constants) > 0 {
+		fmt.Fprintf(c.stdout, "\nconstants:\n%s\n", strings.Join(constants, "\n"))
+	}
+	return EXIT_OK
+}
+
+func scriptArgs(args []string) *object.Array {
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func (c *cli) check(args []string) int {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	code := EXIT_OK
+	for _, path := range args {
+		src, ok := c.read(path)
+		if !ok {
+			return EXIT_IO_ERROR
+		}
+		if _, ok := c.parse(path, src); !ok {
+			code = EXIT_PARSE_ERROR
+		}
+	}
+	return code
+}
+
+func (c *cli) fmt(args []string) int {
+	flags := c.flags("fmt")
+	write := flags.Bool("w", false, "write the result to the source file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return EXIT_USAGE
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		if *write {
+			fmt.Fprintln(c.stderr, "monkey fmt: cannot use -w with stdin")
+			return EXIT_USAGE
+		}
+		paths = []string{""}
+	}
+	for _, path := range paths {
+		src, ok := c.read(path)
+		if !ok {
+			return EXIT_IO_ERROR
+		}
+		formatted, errors := format.Source(src)
+		if len(errors) != 0 {
+			name := path
+			if name == "" {
+				name = "<stdin>"
+			}
+			for _, msg := range errors {
+				fmt.Fprintf(c.stderr, "%s: %s\n", name, msg)
+			}
+			return EXIT_PARSE_ERROR
+		}
+		if !*write {
+			io.WriteString(c.stdout, formatted)
+			continue
+		}
+		if err := os.WriteFile(path, []byte(formatted), 0644); err != nil {
+			fmt.Fprintf(c.stderr, "monkey: %v\n", err)
+			return EXIT_IO_ERROR
+		}
+	}
+	return EXIT_OK
+}
+
+func (c *cli) tokens(args []string) int {
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	}
+	src, ok := c.read(path)
+	if !ok {
+		return EXIT_IO_ERROR
+	}
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(c.stdout, "%-8s %q\n", tok.Type, tok.Literal)
+	}
+	return EXIT_OK
+}
+
+func (c *cli) ast(args []string) int {
+	flags := c.flags("ast")
+	dot := flags.Bool("dot", false, "print the tree as a Graphviz DOT graph")
+	if err := flags.Parse(args); err != nil {
+		return EXIT_USAGE
+	}
+	src, ok := c.read(flags.Arg(0))
+	if !ok {
+		return EXIT_IO_ERROR
+	}
+	program, ok := c.parse(flags.Arg(0), src)
+	if !ok {
+		return EXIT_PARSE_ERROR
+	}
+	if *dot {
+		io.WriteString(c.stdout, ast.Dot(program))
+	} else {
+		io.WriteString(c.stdout, ast.Tree(program))
+	}
+	return EXIT_OK
+}
+
+func (c *cli) repl(args []string) int {
+	flags := c.flags("repl")
+	listen := flags.String("listen", "", "serve sessions on `address`, unix:/path or [tcp:]host:port")
+	sessionTimeout := flags.Duration("session-timeout", 0, "close network sessions after this long")
+	idleTimeout := flags.Duration("idle-timeout", 0, "close idle network sessions after this long")
+	if err := flags.Parse(args); err != nil {
+		return EXIT_USAGE
+	}
+	if *listen != "" {
+		srv := &repl.Server{SessionTimeout: *sessionTimeout, IdleTimeout: *idleTimeout}
+		return c.serve(srv, *listen)
+	}
+	name := "there"
+	if usr, err := user.Current(); err == nil && usr.Name != "" {
+		name = usr.Name
+	}
+	fmt.Fprintf(c.stdout, "Hello %s, this is Monkey Language Terminal\n", name)
+	fmt.Fprintf(c.stdout, "Feel free to type any command! \n")
+	repl.Start(c.stdin, c.stdout)
+	return EXIT_OK
+}
+
+// serve runs srv on address until interrupted.
+func (c *cli) serve(srv *repl.Server, address string) int {
+	network := "tcp"
+	if n, addr, ok := strings.Cut(address, ":"); ok && (n == "unix" || strings.HasPrefix(n, "tcp")) {
+		network, address = n, addr
+	}
+	l, err := net.Listen(network, address)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "monkey: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		l.Close()
+	}()
+
+	fmt.Fprintf(c.stderr, "monkey: serving REPL sessions on %s:%s\n", l.Addr().Network(), l.Addr())
+	if err := srv.Serve(l); err != nil {
+		fmt.Fprintf(c.stderr, "monkey: %v\n", err)
+		return EXIT_IO_ERROR
+	}
+	return EXIT_OK
+}
@@ -0,0 +1,755 @@
+// Package closure evaluates syntax trees by first converting every node
+// into a Go closure, so that running a program no longer dispatches on
+// node types, and locals are read from slots numbered ahead of time
+// instead of being looked up by name.
+//
+// Programs behave as they do under the evaluator, with which the package
+// shares its operators and errors. Globals live in an object.Environment,
+// so hosts see the same bindings whichever way a script is run.
+package closure
+
+import (
+	"context"
+	"fmt"
+	"interpreter/ast"
+	"interpreter/compiler"
+	"interpreter/evaluator"
+	"interpreter/object"
+)
+
+type eval func(f *Frame) object.Object
+
+// Frame holds the locals of one function call, or of none at the top
+// level, and the state of the run making it.
+type Frame struct {
+	slots   []object.Object
+	outer   *Frame
+	globals *object.Environment
+	run     *Runner
+	// returned is set by a return statement, ending the call or program
+	// the frame belongs to.
+	returned bool
+}
+
+// Program is a syntax tree converted to closures. It is immutable and may
+// be run by any number of runners at once.
+type Program struct {
+	run eval
+}
+
+// Function is a function value made by a compiled program.
+type Function struct {
+	fn  *function
+	env *Frame
+}
+
+type function struct {
+	literal   *ast.FunctionLiteral
+	numLocals int
+	body      eval
+}
+
+// tailCall is a call to a compiled function in tail position, returned
+// in place of its result for the call making it to make in turn.
+type tailCall struct {
+	fn   *Function
+	args []object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType { return evaluator.TAIL_CALL_OBJ }
+func (tc *tailCall) Inspect() string         { return "tail call to " + tc.fn.Inspect() }
+
+func (fn *Function) Type() object.ObjectType { return object.FUNCTION_OBJ }
+func (fn *Function) Inspect() string {
+	return (&object.Function{Parameters: fn.fn.literal.Parameters, Body: fn.fn.literal.Body}).Inspect()
+}
+
+// Runner runs compiled programs within the same limits as an
+// evaluator.Evaluator, failing with the same *object.Error values. It
+// keeps count of the steps taken, so it should not be shared between
+// concurrent runs.
+type Runner struct {
+	// MaxSteps caps the number of nodes evaluated; zero means no limit.
+	MaxSteps int
+	// MaxDepth caps nested function calls, which tail calls are not;
+	// zero means evaluator.DEFAULT_MAX_DEPTH.
+	MaxDepth int
+
+	ctx   context.Context
+	steps int
+	depth int
+}
+
+// New returns a Runner that stops with an evaluator.ErrCanceled error once
+// ctx is done. ctx is checked before every top-level statement, loop
+// iteration and function call.
+func New(ctx context.Context) *Runner {
+	return &Runner{ctx: ctx}
+}
+
+// Steps returns the number of nodes evaluated so far.
+func (r *Runner) Steps() int {
+	return r.steps
+}
+
+// Run runs p with globals as its global scope and returns the value of
+// its last statement, or the *object.Error that ended it.
+func (r *Runner) Run(p *Program, globals *object.Environment) object.Object {
+	result := p.run(&Frame{globals: globals, run: r})
+	if result == nil {
+		return object.NULL
+	}
+	return result
+}
+
+// ApplyFunction calls fn, a compiled function, a builtin or an evaluator
+// function, with args.
+func (r *Runner) ApplyFunction(fn object.Object, args []object.Object) object.Object {
+	return r.apply(fn, args)
+}
+
+func (r *Runner) apply(fn object.Object, args []object.Object) object.Object {
+	if err := r.ctx.Err(); err != nil {
+		return evaluator.CanceledError(err)
+	}
+	switch fn := fn.(type) {
+	case *Function:
+		maxDepth := r.maxDepth()
+		if r.depth >= maxDepth {
+			return evaluator.DepthLimitError(maxDepth)
+		}
+		r.depth++
+		result := r.call(fn, args)
+		r.depth--
+		return result
+	case *object.Builtin:
+		return fn.Fn(args...)
+	case *object.Function:
+		return r.applyFunction(fn, args)
+	}
+	return createError("not a function: %s", fn.Type())
+}
+
+// call runs the body of fn, then of each function called in tail position
+// by the last, within one level of depth.
+func (r *Runner) call(fn *Function, args []object.Object) object.Object {
+	for {
+		params := fn.fn.literal.Parameters
+		if len(args) != len(params) {
+			return createError("wrong number of arguments: want=%d, got=%d", len(params), len(args))
+		}
+		frame := &Frame{
+			slots:   make([]object.Object, fn.fn.numLocals),
+			outer:   fn.env,
+			globals: fn.env.globals,
+			run:     r,
+		}
+		copy(frame.slots, args)
+		result := fn.fn.body(frame)
+		call, ok := result.(*tailCall)
+		if !ok {
+			if result == nil {
+				return object.NULL
+			}
+			return result
+		}
+		if err := r.ctx.Err(); err != nil {
+			return evaluator.CanceledError(err)
+		}
+		fn, args = call.fn, call.args
+	}
+}
+
+// applyFunction calls a function of the evaluator within what is left of
+// the Runner's step and depth limits, counting the steps it takes as the
+// Runner's.
+func (r *Runner) applyFunction(fn *object.Function, args []object.Object) object.Object {
+	result, steps := evaluator.ApplyWithin(r.ctx, fn, args, r.MaxSteps, r.steps, r.maxDepth(), r.depth)
+	r.steps = steps
+	return result
+}
+
+func (r *Runner) maxDepth() int {
+	if r.MaxDepth > 0 {
+		return r.MaxDepth
+	}
+	return evaluator.DEFAULT_MAX_DEPTH
+}
+
+// step counts a node evaluated, returning an error once there are more
+// than MaxSteps.
+func (r *Runner) step() *object.Error {
+	r.steps++
+	if r.MaxSteps > 0 && r.steps > r.MaxSteps {
+		return evaluator.StepLimitError(r.MaxSteps)
+	}
+	return nil
+}
+
+// Compile converts program to closures.
+func Compile(program *ast.Program) (*Program, error) {
+	b := &builder{symbols: compiler.NewSymbolTable()}
+	stmts, err := b.statements(program.Statements)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{run: func(f *Frame) object.Object {
+		var result object.Object
+		for _, stmt := range stmts {
+			if err := f.run.ctx.Err(); err != nil {
+				return evaluator.CanceledError(err)
+			}
+			result = stmt(f)
+			if f.stopped(result) {
+				return result
+			}
+		}
+		return result
+	}}, nil
+}
+
+// builder converts nodes, resolving names with the symbol tables of the
+// bytecode compiler: a table per function, the outermost one standing
+// for the globals.
+type builder struct {
+	symbols *compiler.SymbolTable
+}
+
+func (b *builder) statements(stmts []ast.Statement) ([]eval, error) {
+	evals := make([]eval, len(stmts))
+	for i, stmt := range stmts {
+		e, err := b.node(stmt)
+		if err != nil {
+			return nil, err
+		}
+		evals[i] = e
+	}
+	return evals, nil
+}
+
+// tailStatements converts the statements of a function body or of a
+// block in tail position, whose last expression is in tail position too.
+func (b *builder) tailStatements(stmts []ast.Statement) ([]eval, error) {
+	n := len(stmts)
+	if n == 0 {
+		return nil, nil
+	}
+	last, ok := stmts[n-1].(*ast.ExpressionStatement)
+	if !ok {
+		return b.statements(stmts)
+	}
+	evals, err := b.statements(stmts[:n-1])
+	if err != nil {
+		return nil, err
+	}
+	e, err := b.expressionStatement(last, true)
+	if err != nil {
+		return nil, err
+	}
+	return append(evals, e), nil
+}
+
+// block converts a block, in tail position if tail is set.
+func (b *builder) block(block *ast.BlockStatement, tail bool) (eval, error) {
+	stmts, err := b.statements(block.Statements)
+	if tail {
+		stmts, err = b.tailStatements(block.Statements)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func(f *Frame) object.Object {
+		if err := f.run.step(); err != nil {
+			return err
+		}
+		var result object.Object
+		for _, stmt := range stmts {
+			result = stmt(f)
+			if f.stopped(result) {
+				return result
+			}
+		}
+		return result
+	}, nil
+}
+
+func (b *builder) node(node ast.Node) (eval, error) {
+	switch node := node.(type) {
+	case *ast.IntegerLiteral:
+		return constant(&object.Integer{Value: node.Value}), nil
+	case *ast.StringLiteral:
+		return constant(&object.String{Value: node.Value}), nil
+	case *ast.Boolean:
+		return constant(object.NativeBool(node.Value)), nil
+	case *ast.PrefixExpression:
+		return b.prefix(node)
+	case *ast.InfixExpression:
+		return b.infix(node)
+	case *ast.IfExpression:
+		return b.ifExpression(node, false)
+	case *ast.ForStatement:
+		return b.forStatement(node)
+	case *ast.BlockStatement:
+		return b.block(node, false)
+	case *ast.ReturnStatement:
+		// within functions the value returned is in tail position
+		value, err := b.tail(node.ReturnValue, b.symbols.Outer != nil)
+		if err != nil {
+			return nil, err
+		}
+		return func(f *Frame) object.Object {
+			if err := f.run.step(); err != nil {
+				return err
+			}
+			result := value(f)
+			if !isError(result) {
+				f.returned = true
+			}
+			return result
+		}, nil
+	case *ast.ExpressionStatement:
+		return b.expressionStatement(node, false)
+	case *ast.LetStatement:
+		return b.letStatement(node)
+	case *ast.AssignmentStatement:
+		return b.assignmentStatement(node)
+	case *ast.Identifier:
+		return b.identifier(node.Value), nil
+	case *ast.FunctionLiteral:
+		return b.functionLiteral(node)
+	case *ast.CallExpression:
+		return b.call(node, false)
+	}
+	return nil, fmt.Errorf("cannot compile %T", node)
+}
+
+// tail converts an expression, in tail position if tail is set: a call
+// there to a compiled function returns a *tailCall, which an if passes on
+// from its branches.
+func (b *builder) tail(node ast.Expression, tail bool) (eval, error) {
+	if tail {
+		switch node := node.(type) {
+		case *ast.CallExpression:
+			return b.call(node, true)
+		case *ast.IfExpression:
+			return b.ifExpression(node, true)
+		}
+	}
+	return b.node(node)
+}
+
+func (b *builder) expressionStatement(node *ast.ExpressionStatement, tail bool) (eval, error) {
+	expression, err := b.tail(node.Expression, tail)
+	if err != nil {
+		return nil, err
+	}
+	return func(f *Frame) object.Object {
+		if err := f.run.step(); err != nil {
+			return err
+		}
+		return expression(f)
+	}, nil
+}
+
+func constant(obj object.Object) eval {
+	return func(f *Frame) object.Object {
+		if err := f.run.step(); err != nil {
+			return err
+		}
+		return obj
+	}
+}
+
+func (b *builder) prefix(node *ast.PrefixExpression) (eval, error) {
+	right, err := b.node(node.Right)
+	if err != nil {
+		return nil, err
+	}
+	operator := node.Operator
+	return func(f *Frame) object.Object {
+		if err := f.run.step(); err != nil {
+			return err
+		}
+		value := right(f)
+		if f.stopped(value) {
+			return value
+		}
+		switch operator {
+		case "!":
+			return object.NativeBool(!evaluator.IsTruthy(value))
+		case "-":
+			if integer, ok := value.(*object.Integer); ok {
+				return object.NewInteger(-integer.Value)
+			}
+		}
+		return evaluator.EvalPrefix(operator, value)
+	}, nil
+}
+
+func (b *builder) infix(node *ast.InfixExpression) (eval, error) {
+	left, err := b.node(node.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := b.node(node.Right)
+	if err != nil {
+		return nil, err
+	}
+	operator := node.Operator
+	integerOp := integerOperations[operator]
+	return func(f *Frame) object.Object {
+		if err := f.run.step(); err != nil {
+			return err
+		}
+		l := left(f)
+		if f.stopped(l) {
+			return l
+		}
+		r := right(f)
+		if f.stopped(r) {
+			return r
+		}
+		if li, ok := l.(*object.Integer); ok && integerOp != nil {
+			if ri, ok := r.(*object.Integer); ok {
+				if result := integerOp(li.Value, ri.Value); result != nil {
+					return result
+				}
+			}
+		}
+		return evaluator.EvalInfix(l, operator, r)
+	}, nil
+}
+
+// integerOperations compute the integer infix operators, leaving division
+// by zero to the evaluator to report.
+var integerOperations = map[string]func(l, r int64) object.Object{
+	"+": func(l, r int64) object.Object { return object.NewInteger(l + r) },
+	"-": func(l, r int64) object.Object { return object.NewInteger(l - r) },
+	"*": func(l, r int64) object.Object { return object.NewInteger(l * r) },
+	"/": func(l, r int64) object.Object {
+		if r == 0 {
+			return nil
+		}
+		return object.NewInteger(l / r)
+	},
+	"<":  func(l, r int64) object.Object { return object.NativeBool(l < r) },
+	">":  func(l, r int64) object.Object { return object.NativeBool(l > r) },
+	"==": func(l, r int64) object.Object { return object.NativeBool(l == r) },
+	"!=": func(l, r int64) object.Object { return object.NativeBool(l != r) },
+}
+
+func (b *builder) ifExpression(node *ast.IfExpression, tail bool) (eval, error) {
+	condition, err := b.node(node.Condition)
+	if err != nil {
+		return nil, err
+	}
+	consequence, err := b.block(node.Consequence, tail)
+	if err != nil {
+		return nil, err
+	}
+	alternative := constant(object.NULL)
+	if node.Alternative != nil {
+		if alternative, err = b.block(node.Alternative, tail); err != nil {
+			return nil, err
+		}
+	}
+	return func(f *Frame) object.Object {
+		if err := f.run.step(); err != nil {
+			return err
+		}
+		value := condition(f)
+		if f.stopped(value) {
+			return value
+		}
+		if evaluator.IsTruthy(value) {
+			return consequence(f)
+		}
+		return alternative(f)
+	}, nil
+}
+
+func (b *builder) forStatement(node *ast.ForStatement) (eval, error) {
+	condition, err := b.node(node.Condition)
+	if err != nil {
+		return nil, err
+	}
+	block, err := b.block(node.Block, false)
+	if err != nil {
+		return nil, err
+	}
+	return func(f *Frame) object.Object {
+		if err := f.run.step(); err != nil {
+			return err
+		}
+		for {
+			if err := f.run.ctx.Err(); err != nil {
+				return evaluator.CanceledError(err)
+			}
+			value := condition(f)
+			if f.stopped(value) {
+				return value
+			}
+			if !evaluator.IsTruthy(value) {
+				return nil
+			}
+			result := block(f)
+			if f.stopped(result) {
+				return result
+			}
+		}
+	}, nil
+}
+
+func (b *builder) letStatement(node *ast.LetStatement) (eval, error) {
+	name := node.Name.Value
+	// Within functions a function is given its slot first so that it can
+	// call itself, see hoistFunctions.
+	var symbol compiler.Symbol
+	_, isFunction := node.Value.(*ast.FunctionLiteral)
+	if isFunction {
+		symbol = b.symbols.Define(name)
+	}
+	value, err := b.node(node.Value)
+	if err != nil {
+		return nil, err
+	}
+	if !isFunction {
+		symbol = b.symbols.Define(name)
+	}
+	set := b.setter(symbol)
+	return func(f *Frame) object.Object {
+		if err := f.run.step(); err != nil {
+			return err
+		}
+		result := value(f)
+		if f.stopped(result) {
+			return result
+		}
+		set(f, result)
+		return nil
+	}, nil
+}
+
+func (b *builder) assignmentStatement(node *ast.AssignmentStatement) (eval, error) {
+	name := node.Ident.Value
+	value, err := b.node(node.Value)
+	if err != nil {
+		return nil, err
+	}
+	symbol, ok := b.symbols.Resolve(name)
+	if !ok || symbol.Scope == compiler.GLOBAL_SCOPE {
+		return func(f *Frame) object.Object {
+			if err := f.run.step(); err != nil {
+				return err
+			}
+			scope := f.globals.Scope(name)
+			if scope == nil {
+				return notFoundError(name)
+			}
+			result := value(f)
+			if f.stopped(result) {
+				return result
+			}
+			scope.Set(name, result)
+			return nil
+		}, nil
+	}
+	get, set := b.getter(symbol), b.setter(symbol)
+	return func(f *Frame) object.Object {
+		if err := f.run.step(); err != nil {
+			return err
+		}
+		if current := get(f); isError(current) {
+			return current
+		}
+		result := value(f)
+		if f.stopped(result) {
+			return result
+		}
+		set(f, result)
+		return nil
+	}, nil
+}
+
+func (b *builder) identifier(name string) eval {
+	symbol, ok := b.symbols.Resolve(name)
+	if !ok {
+		symbol = compiler.Symbol{Name: name, Scope: compiler.GLOBAL_SCOPE}
+	}
+	get := b.getter(symbol)
+	return func(f *Frame) object.Object {
+		if err := f.run.step(); err != nil {
+			return err
+		}
+		return get(f)
+	}
+}
+
+// getter returns a function reading symbol, or an error if it is not
+// bound.
+func (b *builder) getter(symbol compiler.Symbol) eval {
+	name, index, depth := symbol.Name, symbol.Index, symbol.Depth
+	switch symbol.Scope {
+	case compiler.LOCAL_SCOPE:
+		return func(f *Frame) object.Object {
+			if value := f.slots[index]; value != nil {
+				return value
+			}
+			return notFoundError(name)
+		}
+	case compiler.OUTER_SCOPE:
+		return func(f *Frame) object.Object {
+			scope := f
+			for i := 0; i < depth; i++ {
+				scope = scope.outer
+			}
+			if value := scope.slots[index]; value != nil {
+				return value
+			}
+			return notFoundError(name)
+		}
+	}
+	return func(f *Frame) object.Object {
+		if value, ok := f.globals.Get(name); ok {
+			return value
+		}
+		return notFoundError(name)
+	}
+}
+
+func (b *builder) setter(symbol compiler.Symbol) func(*Frame, object.Object) {
+	name, index, depth := symbol.Name, symbol.Index, symbol.Depth
+	switch symbol.Scope {
+	case compiler.LOCAL_SCOPE:
+		return func(f *Frame, value object.Object) {
+			f.slots[index] = value
+		}
+	case compiler.OUTER_SCOPE:
+		return func(f *Frame, value object.Object) {
+			scope := f
+			for i := 0; i < depth; i++ {
+				scope = scope.outer
+			}
+			scope.slots[index] = value
+		}
+	}
+	return func(f *Frame, value object.Object) {
+		f.globals.Set(name, value)
+	}
+}
+
+func (b *builder) functionLiteral(node *ast.FunctionLiteral) (eval, error) {
+	b.symbols = compiler.NewEnclosedSymbolTable(b.symbols)
+	defer func() { b.symbols = b.symbols.Outer }()
+	for _, param := range node.Parameters {
+		b.symbols.Define(param.Value)
+	}
+	b.hoistFunctions(node.Body)
+	stmts, err := b.tailStatements(node.Body.Statements)
+	if err != nil {
+		return nil, err
+	}
+	fn := &function{literal: node}
+	fn.body = func(f *Frame) object.Object {
+		var result object.Object
+		for _, stmt := range stmts {
+			result = stmt(f)
+			if f.stopped(result) {
+				return result
+			}
+		}
+		return result
+	}
+	// Functions defined below are given slots as they are converted, so
+	// the count is only known now.
+	fn.numLocals = len(b.symbols.Names())
+	return func(f *Frame) object.Object {
+		if err := f.run.step(); err != nil {
+			return err
+		}
+		return &Function{fn: fn, env: f}
+	}, nil
+}
+
+// hoistFunctions gives the functions a function body binds with let
+// their slots before converting it, so that closures within can call
+// functions defined after them, as they would find them under the
+// evaluator.
+func (b *builder) hoistFunctions(body *ast.BlockStatement) {
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.LetStatement:
+			if _, ok := node.Value.(*ast.FunctionLiteral); ok {
+				b.symbols.Define(node.Name.Value)
+			}
+			return false
+		}
+		return true
+	})
+}
+
+// call converts a call, which in tail position leaves calling a compiled
+// function to the call that made the function running it.
+func (b *builder) call(node *ast.CallExpression, tail bool) (eval, error) {
+	function, err := b.node(node.Function)
+	if err != nil {
+		return nil, err
+	}
+	args, err := b.expressions(node.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	return func(f *Frame) object.Object {
+		if err := f.run.step(); err != nil {
+			return err
+		}
+		fn := function(f)
+		if f.stopped(fn) {
+			return fn
+		}
+		values := make([]object.Object, len(args))
+		for i, arg := range args {
+			value := arg(f)
+			if f.stopped(value) {
+				return value
+			}
+			values[i] = value
+		}
+		if fn, ok := fn.(*Function); ok && tail {
+			return &tailCall{fn: fn, args: values}
+		}
+		return f.run.apply(fn, values)
+	}, nil
+}
+
+func (b *builder) expressions(exps []ast.Expression) ([]eval, error) {
+	evals := make([]eval, len(exps))
+	for i, exp := range exps {
+		e, err := b.node(exp)
+		if err != nil {
+			return nil, err
+		}
+		evals[i] = e
+	}
+	return evals, nil
+}
+
+// stopped reports whether value ends what is being evaluated in f: it is
+// an error, or a return statement within it has ended the call.
+func (f *Frame) stopped(value object.Object) bool {
+	return f.returned || isError(value)
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
+
+func createError(format string, args ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, args...)}
+}
+
+func notFoundError(name string) *object.Error {
+	return createError("identifier not found: %s", name)
+}
@@ -0,0 +1,180 @@
+package closure
+
+import (
+	"context"
+	"errors"
+	"interpreter/evaluator"
+	"interpreter/evaluator/evaltest"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"testing"
+	"time"
+)
+
+func compile(t testing.TB, input string) *Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("%s: parse errors %v", input, p.Errors())
+	}
+	compiled, err := Compile(program)
+	if err != nil {
+		t.Fatalf("%s: %v", input, err)
+	}
+	return compiled
+}
+
+func TestParity(t *testing.T) {
+	for _, input := range evaltest.Corpus {
+		want := evaltest.Eval(input)
+		env := object.NewEnvironment()
+		for name, builtin := range evaltest.Builtins {
+			env.Set(name, builtin)
+		}
+		got := New(context.Background()).Run(compile(t, input), env)
+		if got.Type() != want.Type() || got.Inspect() != want.Inspect() {
+			t.Errorf("%s: closures = %s, evaluator = %s", input, got.Inspect(), want.Inspect())
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-expired.Done()
+
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		maxSteps int
+		maxDepth int
+		expected error
+		message  string
+	}{
+		{"for (true) {}", expired, 0, 0, context.DeadlineExceeded, "evaluation canceled: context deadline exceeded"},
+		{"for (true) {}", context.Background(), 1000, 0, evaluator.ErrStepLimit, "step limit of 1000 exceeded"},
+		{"let f = fn(n) { 1 + f(n + 1) }; f(0)", context.Background(), 0, 50, evaluator.ErrDepthLimit, "maximum recursion depth of 50 exceeded"},
+		{"let f = fn(n) { 1 + f(n + 1) }; f(0)", context.Background(), 0, 0, evaluator.ErrDepthLimit, "maximum recursion depth of 10000 exceeded"},
+	}
+	for _, tt := range tests {
+		r := New(tt.ctx)
+		r.MaxSteps = tt.maxSteps
+		r.MaxDepth = tt.maxDepth
+		errorObj, ok := r.Run(compile(t, tt.input), object.NewEnvironment()).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if !errors.Is(errorObj.Err, tt.expected) || errorObj.Message != tt.message {
+			t.Errorf("%s: got %q (%v), want %q (%v)", tt.input, errorObj.Message, errorObj.Err, tt.message, tt.expected)
+		}
+	}
+}
+
+func TestEvaluatorFunctionLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		maxSteps int
+		maxDepth int
+		expected error
+		message  string
+	}{
+		{"spin()", 1000, 0, evaluator.ErrStepLimit, "step limit of 1000 exceeded"},
+		{"deep(100)", 0, 50, evaluator.ErrDepthLimit, "maximum recursion depth of 50 exceeded"},
+		{"let f = fn(n) { if (n == 0) { deep(40) } else { 1 + f(n - 1) } }; f(20)", 0, 50, evaluator.ErrDepthLimit, "maximum recursion depth of 50 exceeded"},
+		{"deep(40)", 0, 50, nil, ""},
+	}
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		evaluator.Eval(parser.New(lexer.New(
+			"let spin = fn() { for (true) {} }; let deep = fn(n) { if (n == 0) { 0 } else { 1 + deep(n - 1) } };",
+		)).ParseProgram(), env)
+		r := New(context.Background())
+		r.MaxSteps = tt.maxSteps
+		r.MaxDepth = tt.maxDepth
+		result := r.Run(compile(t, tt.input), env)
+		errorObj, ok := result.(*object.Error)
+		if tt.expected == nil {
+			if ok {
+				t.Errorf("%s: unexpected error %q", tt.input, errorObj.Message)
+			}
+			continue
+		}
+		if !ok || !errors.Is(errorObj.Err, tt.expected) || errorObj.Message != tt.message {
+			t.Errorf("%s: got %s, want %q (%v)", tt.input, result.Inspect(), tt.message, tt.expected)
+		}
+	}
+}
+
+func TestGlobalsAreShared(t *testing.T) {
+	env := object.NewEnvironment()
+	r := New(context.Background())
+	for _, input := range []string{"let a = 40;", "let f = fn() { a + b };", "let b = 2;"} {
+		if result := r.Run(compile(t, input), env); result.Type() == object.ERROR_OBJ {
+			t.Fatalf("%s: %s", input, result.Inspect())
+		}
+	}
+	f, _ := env.Get("f")
+	if result := r.ApplyFunction(f, nil); result.Inspect() != "42" {
+		t.Errorf("f() = %s, want 42", result.Inspect())
+	}
+	if got, want := f.Inspect(), "fn() {\n(a + b)\n}"; got != want {
+		t.Errorf("f.Inspect() = %q, want %q", got, want)
+	}
+
+	// Functions the evaluator made can be called too.
+	evaluator.Eval(parser.New(lexer.New("let g = fn(x) { a + x };")).ParseProgram(), env)
+	if result := r.Run(compile(t, "g(3)"), env); result.Inspect() != "43" {
+		t.Errorf("g(3) = %s, want 43", result.Inspect())
+	}
+}
+
+const fibScript = "let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(20)"
+
+const loopScript = "let i = 0; let s = 0; for (i < 100000) { s = s + i * 2; i = i + 1; } s"
+
+func BenchmarkFib(b *testing.B) {
+	benchmarkBackends(b, fibScript)
+}
+
+func BenchmarkLoop(b *testing.B) {
+	benchmarkBackends(b, loopScript)
+}
+
+func benchmarkBackends(b *testing.B, script string) {
+	b.Run("evaluator", func(b *testing.B) {
+		program := parser.New(lexer.New(script)).ParseProgram()
+		for i := 0; i < b.N; i++ {
+			evaluator.Eval(program, object.NewEnvironment())
+		}
+	})
+	b.Run("closures", func(b *testing.B) {
+		compiled := compile(b, script)
+		for i := 0; i < b.N; i++ {
+			New(context.Background()).Run(compiled, object.NewEnvironment())
+		}
+	})
+}
+
+func TestReturnWithinExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = if (true) { return 5; }; 10", "5"},
+		{"let x = 1; x = if (true) { return 5; }; 10", "5"},
+		{"1 + if (true) { return 5; }; 10", "5"},
+		{"-if (true) { return 5; }; 10", "5"},
+		{"let f = fn(x) { x }; f(if (true) { return 5; }); 10", "5"},
+		{"if (if (true) { return 5; }) { 1 }; 10", "5"},
+		{"let f = fn() { let x = if (true) { return 5; }; 10 }; f() + 1", "6"},
+	}
+	for _, tt := range tests {
+		result := New(context.Background()).Run(compile(t, tt.input), object.NewEnvironment())
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+}
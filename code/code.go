@@ -0,0 +1,235 @@
+// Package code defines the bytecode instructions the compiler emits and
+// the virtual machine executes.
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Instructions []byte
+
+type Opcode byte
+
+const (
+	// OpConstant pushes the constant at operand 0.
+	OpConstant Opcode = iota
+	// OpPop discards the top of the stack.
+	OpPop
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	OpMinus
+	OpBang
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	// OpJump continues at operand 0; OpJumpNotTruthy does so after popping
+	// a value that is not truthy.
+	OpJump
+	OpJumpNotTruthy
+
+	// OpSetGlobal binds a global, OpAssignGlobal rebinds one that must be
+	// bound already.
+	OpGetGlobal
+	OpSetGlobal
+	OpAssignGlobal
+	OpGetLocal
+	OpSetLocal
+	// OpGetOuter and OpSetOuter access local operand 1 of the scope
+	// operand 0 levels out from the current call.
+	OpGetOuter
+	OpSetOuter
+
+	// OpClosure pushes a closure of the function constant at operand 0
+	// over the current scope.
+	OpClosure
+	// OpCall calls the function below its operand 0 arguments.
+	OpCall
+	OpReturnValue
+	OpReturn
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant:      {"OpConstant", []int{2}},
+	OpPop:           {"OpPop", []int{}},
+	OpAdd:           {"OpAdd", []int{}},
+	OpSub:           {"OpSub", []int{}},
+	OpMul:           {"OpMul", []int{}},
+	OpDiv:           {"OpDiv", []int{}},
+	OpEqual:         {"OpEqual", []int{}},
+	OpNotEqual:      {"OpNotEqual", []int{}},
+	OpGreaterThan:   {"OpGreaterThan", []int{}},
+	OpLessThan:      {"OpLessThan", []int{}},
+	OpMinus:         {"OpMinus", []int{}},
+	OpBang:          {"OpBang", []int{}},
+	OpTrue:          {"OpTrue", []int{}},
+	OpFalse:         {"OpFalse", []int{}},
+	OpNull:          {"OpNull", []int{}},
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpGetGlobal:     {"OpGetGlobal", []int{2}},
+	OpSetGlobal:     {"OpSetGlobal", []int{2}},
+	OpAssignGlobal:  {"OpAssignGlobal", []int{2}},
+	OpGetLocal:      {"OpGetLocal", []int{1}},
+	OpSetLocal:      {"OpSetLocal", []int{1}},
+	OpGetOuter:      {"OpGetOuter", []int{1, 1}},
+	OpSetOuter:      {"OpSetOuter", []int{1, 1}},
+	OpClosure:       {"OpClosure", []int{2}},
+	OpCall:          {"OpCall", []int{1}},
+	OpReturnValue:   {"OpReturnValue", []int{}},
+	OpReturn:        {"OpReturn", []int{}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes op and its operands, big-endian, as one instruction.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+	length := 1 + def.Width()
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+	offset := 1
+	for i, o := range operands {
+		switch def.OperandWidths[i] {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += def.OperandWidths[i]
+	}
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction defined by def from
+// ins, returning them and the number of bytes read.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return ins[0]
+}
+
+// String disassembles ins, one instruction per line prefixed with its
+// offset.
+func (ins Instructions) String() string {
+	return ins.Disassemble(nil, nil)
+}
+
+// Disassemble is String with the instructions annotated with the source
+// lines they were compiled from: a `; line N` comment, or `; N: text`
+// when source holds the lines of the source, precedes each run of
+// instructions from one line.
+func (ins Instructions) Disassemble(lines LineTable, source []string) string {
+	var out bytes.Buffer
+	line := 0
+	i := 0
+	for i < len(ins) {
+		if l := lines.Line(i); l != line && l > 0 {
+			line = l
+			if line <= len(source) {
+				fmt.Fprintf(&out, "; %d: %s\n", line, strings.TrimSpace(source[line-1]))
+			} else {
+				fmt.Fprintf(&out, "; line %d\n", line)
+			}
+		}
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+		if i+1+def.Width() > len(ins) {
+			fmt.Fprintf(&out, "ERROR: %s at %d is truncated\n", def.Name, i)
+			break
+		}
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+		i += 1 + read
+	}
+	return out.String()
+}
+
+// Width returns the number of bytes taken by the operands of def.
+func (def *Definition) Width() int {
+	width := 0
+	for _, w := range def.OperandWidths {
+		width += w
+	}
+	return width
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	switch len(def.OperandWidths) {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+	return fmt.Sprintf("ERROR: unhandled operand count for %s", def.Name)
+}
+
+// Line maps the instructions from Offset up to the next entry of a
+// LineTable to a source line.
+type Line struct {
+	Offset int
+	Line   int
+}
+
+// LineTable maps instruction offsets to source lines, in increasing order
+// of offset.
+type LineTable []Line
+
+// Line returns the source line of the instruction at offset, or 0 if it
+// is not known.
+func (t LineTable) Line(offset int) int {
+	i := sort.Search(len(t), func(i int) bool { return t[i].Offset > offset })
+	if i == 0 {
+		return 0
+	}
+	return t[i-1].Line
+}
@@ -0,0 +1,122 @@
+package code
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpGetOuter, []int{2, 7}, []byte{byte(OpGetOuter), 2, 7}},
+	}
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+		if string(instruction) != string(tt.expected) {
+			t.Errorf("Make(%d, %v) = %v, want %v", tt.op, tt.operands, instruction, tt.expected)
+		}
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{255}, 1},
+		{OpSetOuter, []int{1, 3}, 2},
+	}
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatal(err)
+		}
+		operands, n := ReadOperands(def, instruction[1:])
+		if n != tt.bytesRead {
+			t.Errorf("%s: read %d bytes, want %d", def.Name, n, tt.bytesRead)
+		}
+		for i, want := range tt.operands {
+			if operands[i] != want {
+				t.Errorf("%s: operand %d = %d, want %d", def.Name, i, operands[i], want)
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpGetOuter, 1, 2),
+	}
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpGetOuter 1 2
+`
+	var concatted Instructions
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\ngot=%q", expected, concatted.String())
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	ins := concat(
+		Make(OpConstant, 0),
+		Make(OpSetGlobal, 0),
+		Make(OpGetGlobal, 0),
+		Make(OpReturnValue),
+	)
+	lines := LineTable{{0, 1}, {6, 3}}
+	expected := `; 1: let a = 1;
+0000 OpConstant 0
+0003 OpSetGlobal 0
+; 3: a
+0006 OpGetGlobal 0
+0009 OpReturnValue
+`
+	if got := ins.Disassemble(lines, []string{"let a = 1;", "", "  a"}); got != expected {
+		t.Errorf("want:\n%s\ngot:\n%s", expected, got)
+	}
+	if got := ins.Disassemble(lines, nil); !strings.HasPrefix(got, "; line 1\n0000") {
+		t.Errorf("expected line numbers without source, got:\n%s", got)
+	}
+	if got := (Instructions{byte(OpConstant), 0}).String(); got != "ERROR: OpConstant at 0 is truncated\n" {
+		t.Errorf("truncated instruction disassembled as %q", got)
+	}
+}
+
+func TestLineTable(t *testing.T) {
+	lines := LineTable{{0, 1}, {4, 2}, {9, 5}}
+	for offset, want := range map[int]int{0: 1, 3: 1, 4: 2, 8: 2, 9: 5, 100: 5} {
+		if got := lines.Line(offset); got != want {
+			t.Errorf("Line(%d) = %d, want %d", offset, got, want)
+		}
+	}
+	if got := (LineTable{{2, 1}}).Line(0); got != 0 {
+		t.Errorf("Line before the first entry = %d, want 0", got)
+	}
+}
+
+func concat(instructions ...[]byte) Instructions {
+	var out Instructions
+	for _, ins := range instructions {
+		out = append(out, ins...)
+	}
+	return out
+}
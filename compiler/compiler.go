@@ -0,0 +1,402 @@
+// Package compiler lowers syntax trees to bytecode for the vm package.
+//
+// Compiled programs behave as the evaluator does. Closures share the
+// variables they capture with the function that made them, and names
+// that are not defined when a function is compiled are taken to be
+// globals, checked when they are used, so functions may refer to globals
+// defined after them.
+package compiler
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/code"
+	"interpreter/object"
+)
+
+// MAX_LOCALS bounds the locals of one function, parameters included.
+const MAX_LOCALS = 255
+
+// MAX_INSTRUCTIONS bounds the instruction bytes of the program and of each
+// function, so that every jump target fits its 2-byte operand.
+const MAX_INSTRUCTIONS = 1 << 16
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	// Globals names the global slots.
+	Globals []string
+	// Lines maps Instructions to source lines; compiled functions carry
+	// their own.
+	Lines code.LineTable
+	// Source names the file compiled, if any.
+	Source string
+}
+
+type Compiler struct {
+	constants    []object.Object
+	symbols      *SymbolTable
+	instructions code.Instructions
+	lines        code.LineTable
+	// line is the source line of the node being compiled.
+	line int
+}
+
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), nil)
+}
+
+// NewWithState returns a Compiler that continues from the globals in
+// symbols and the constants of an earlier compilation, so that programs
+// can be compiled one at a time against the same VM globals. Builtins are
+// made available by defining them in symbols first.
+func NewWithState(symbols *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{symbols: symbols, constants: constants}
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	global := c.symbols.Global()
+	return &Bytecode{
+		Instructions: c.instructions,
+		Constants:    c.constants,
+		Globals:      append([]string(nil), global.Names()...),
+		Lines:        c.lines,
+	}
+}
+
+// Compile compiles program into instructions that leave the value of the
+// program, as the evaluator would return it, with OpReturnValue.
+func (c *Compiler) Compile(program *ast.Program) error {
+	c.instructions = nil
+	c.lines = nil
+	if err := c.compileValue(program.Statements); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+	if len(c.instructions) > MAX_INSTRUCTIONS {
+		return fmt.Errorf("program too long: %d bytes of instructions", len(c.instructions))
+	}
+	if len(c.constants) > 1<<16 {
+		return fmt.Errorf("too many constants: %d", len(c.constants))
+	}
+	if len(c.symbols.Global().Names()) > 1<<16 {
+		return fmt.Errorf("too many globals: %d", len(c.symbols.Global().Names()))
+	}
+	return nil
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	if line := ast.Line(node); line > 0 && line != c.line {
+		outer := c.line
+		c.line = line
+		defer func() { c.line = outer }()
+	}
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		if err := c.compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.LetStatement:
+		return c.compileLetStatement(node)
+	case *ast.AssignmentStatement:
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		symbol := c.resolve(node.Ident.Value)
+		if symbol.Scope == GLOBAL_SCOPE {
+			c.emit(code.OpAssignGlobal, symbol.Index)
+		} else {
+			c.store(symbol)
+		}
+	case *ast.ReturnStatement:
+		if err := c.compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			if err := c.compile(stmt); err != nil {
+				return err
+			}
+		}
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.PrefixExpression:
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+	case *ast.InfixExpression:
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.Identifier:
+		c.load(c.resolve(node.Value))
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+	case *ast.CallExpression:
+		if len(node.Arguments) > 255 {
+			return fmt.Errorf("too many arguments: %d", len(node.Arguments))
+		}
+		if err := c.compile(node.Function); err != nil {
+			return err
+		}
+		for _, arg := range node.Arguments {
+			if err := c.compile(arg); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+	return nil
+}
+
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+}
+
+// compileValue compiles stmts so that they leave the value of the last
+// one, or null if it is not an expression.
+func (c *Compiler) compileValue(stmts []ast.Statement) error {
+	if len(stmts) == 0 {
+		c.emit(code.OpNull)
+		return nil
+	}
+	for _, stmt := range stmts[:len(stmts)-1] {
+		if err := c.compile(stmt); err != nil {
+			return err
+		}
+	}
+	switch last := stmts[len(stmts)-1].(type) {
+	case *ast.ExpressionStatement:
+		return c.compile(last.Expression)
+	case *ast.ReturnStatement:
+		return c.compile(last)
+	default:
+		if err := c.compile(last); err != nil {
+			return err
+		}
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+func (c *Compiler) compileLetStatement(node *ast.LetStatement) error {
+	// A function is bound before it is compiled so that it can call
+	// itself, as it finds its own binding when called by the evaluator.
+	if _, ok := node.Value.(*ast.FunctionLiteral); ok {
+		symbol, err := c.define(node.Name.Value)
+		if err != nil {
+			return err
+		}
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		c.store(symbol)
+		return nil
+	}
+	if err := c.compile(node.Value); err != nil {
+		return err
+	}
+	symbol, err := c.define(node.Name.Value)
+	if err != nil {
+		return err
+	}
+	c.store(symbol)
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 0)
+	if err := c.compileValue(node.Consequence.Statements); err != nil {
+		return err
+	}
+	jump := c.emit(code.OpJump, 0)
+	c.patch(jumpNotTruthy, len(c.instructions))
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileValue(node.Alternative.Statements); err != nil {
+		return err
+	}
+	c.patch(jump, len(c.instructions))
+	return nil
+}
+
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	start := len(c.instructions)
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+	exit := c.emit(code.OpJumpNotTruthy, 0)
+	if err := c.compile(node.Block); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+	c.patch(exit, len(c.instructions))
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	outer, outerLines := c.instructions, c.lines
+	c.instructions, c.lines = nil, nil
+	c.symbols = NewEnclosedSymbolTable(c.symbols)
+	leave := func() {
+		c.symbols = c.symbols.Outer
+	}
+
+	for _, param := range node.Parameters {
+		if _, err := c.define(param.Value); err != nil {
+			leave()
+			return err
+		}
+	}
+	if err := c.hoistFunctions(node.Body); err != nil {
+		leave()
+		return err
+	}
+	if err := c.compileValue(node.Body.Statements); err != nil {
+		leave()
+		return err
+	}
+	line := c.line
+	if n := len(node.Body.Statements); n > 0 {
+		c.line = ast.Line(node.Body.Statements[n-1])
+	}
+	c.emit(code.OpReturnValue)
+	c.line = line
+	if len(c.instructions) > MAX_INSTRUCTIONS {
+		leave()
+		return fmt.Errorf("function too long: %d bytes of instructions", len(c.instructions))
+	}
+
+	fn := &object.CompiledFunction{
+		Instructions:  c.instructions,
+		NumLocals:     len(c.symbols.Names()),
+		NumParameters: len(node.Parameters),
+		Locals:        c.symbols.Names(),
+		Lines:         c.lines,
+	}
+	leave()
+	c.instructions, c.lines = outer, outerLines
+	c.emit(code.OpClosure, c.addConstant(fn))
+	return nil
+}
+
+// hoistFunctions defines the functions a function body binds with let
+// before compiling it, so that closures within can call functions defined
+// after them, as they would find them when called by the evaluator.
+func (c *Compiler) hoistFunctions(body *ast.BlockStatement) error {
+	var err error
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.LetStatement:
+			if _, ok := node.Value.(*ast.FunctionLiteral); ok && err == nil {
+				_, err = c.define(node.Name.Value)
+			}
+			return false
+		}
+		return true
+	})
+	return err
+}
+
+func (c *Compiler) define(name string) (Symbol, error) {
+	symbol := c.symbols.Define(name)
+	if symbol.Scope == LOCAL_SCOPE && symbol.Index >= MAX_LOCALS {
+		return symbol, fmt.Errorf("too many locals: more than %d", MAX_LOCALS)
+	}
+	return symbol, nil
+}
+
+// resolve returns the symbol for name, taking names that are not defined
+// yet to be globals.
+func (c *Compiler) resolve(name string) Symbol {
+	if symbol, ok := c.symbols.Resolve(name); ok {
+		return symbol
+	}
+	return c.symbols.Global().Define(name)
+}
+
+func (c *Compiler) load(symbol Symbol) {
+	switch symbol.Scope {
+	case GLOBAL_SCOPE:
+		c.emit(code.OpGetGlobal, symbol.Index)
+	case LOCAL_SCOPE:
+		c.emit(code.OpGetLocal, symbol.Index)
+	case OUTER_SCOPE:
+		c.emit(code.OpGetOuter, symbol.Depth, symbol.Index)
+	}
+}
+
+func (c *Compiler) store(symbol Symbol) {
+	switch symbol.Scope {
+	case GLOBAL_SCOPE:
+		c.emit(code.OpSetGlobal, symbol.Index)
+	case LOCAL_SCOPE:
+		c.emit(code.OpSetLocal, symbol.Index)
+	case OUTER_SCOPE:
+		c.emit(code.OpSetOuter, symbol.Depth, symbol.Index)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// emit appends an instruction and returns its position.
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	pos := len(c.instructions)
+	if n := len(c.lines); c.line > 0 && (n == 0 || c.lines[n-1].Line != c.line) {
+		c.lines = append(c.lines, code.Line{Offset: pos, Line: c.line})
+	}
+	c.instructions = append(c.instructions, code.Make(op, operands...)...)
+	return pos
+}
+
+// patch sets the target of the jump at pos.
+func (c *Compiler) patch(pos, target int) {
+	copy(c.instructions[pos:], code.Make(code.Opcode(c.instructions[pos]), target))
+}
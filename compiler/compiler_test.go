@@ -0,0 +1,173 @@
+package compiler
+
+import (
+	"interpreter/code"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"strings"
+	"testing"
+)
+
+func concat(instructions ...[]byte) code.Instructions {
+	var out code.Instructions
+	for _, ins := range instructions {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func compile(t *testing.T, input string) *Bytecode {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("%s: parse errors %v", input, p.Errors())
+	}
+	c := New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("%s: %v", input, err)
+	}
+	return c.Bytecode()
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected code.Instructions
+	}{
+		{"1 + 2", concat(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpAdd),
+			code.Make(code.OpReturnValue),
+		)},
+		{"1; -2", concat(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpPop),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpMinus),
+			code.Make(code.OpReturnValue),
+		)},
+		{"let a = 1;", concat(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpNull),
+			code.Make(code.OpReturnValue),
+		)},
+		{"if (true) { 10 }", concat(
+			code.Make(code.OpTrue),
+			code.Make(code.OpJumpNotTruthy, 10),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpJump, 11),
+			code.Make(code.OpNull),
+			code.Make(code.OpReturnValue),
+		)},
+		{"a = 1;", concat(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpAssignGlobal, 0),
+			code.Make(code.OpNull),
+			code.Make(code.OpReturnValue),
+		)},
+		{"for (false) { 1 }", concat(
+			code.Make(code.OpFalse),
+			code.Make(code.OpJumpNotTruthy, 11),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpPop),
+			code.Make(code.OpJump, 0),
+			code.Make(code.OpNull),
+			code.Make(code.OpReturnValue),
+		)},
+		{"fn(x) { x }(1)", concat(
+			code.Make(code.OpClosure, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpCall, 1),
+			code.Make(code.OpReturnValue),
+		)},
+	}
+	for _, tt := range tests {
+		bytecode := compile(t, tt.input)
+		if bytecode.Instructions.String() != tt.expected.String() {
+			t.Errorf("%s: wrong instructions.\nwant:\n%s\ngot:\n%s", tt.input, tt.expected, bytecode.Instructions)
+		}
+	}
+}
+
+func TestCompileClosures(t *testing.T) {
+	bytecode := compile(t, "fn(a) { let b = 1; fn(c) { a = c; b } }")
+	inner, ok := bytecode.Constants[1].(*object.CompiledFunction)
+	if !ok {
+		t.Fatalf("constant 1 is %T, want *object.CompiledFunction", bytecode.Constants[1])
+	}
+	expected := concat(
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpSetOuter, 1, 0),
+		code.Make(code.OpGetOuter, 1, 1),
+		code.Make(code.OpReturnValue),
+	)
+	if code.Instructions(inner.Instructions).String() != expected.String() {
+		t.Errorf("wrong inner instructions.\nwant:\n%s\ngot:\n%s", expected, code.Instructions(inner.Instructions))
+	}
+	outer := bytecode.Constants[2].(*object.CompiledFunction)
+	if outer.NumLocals != 2 || outer.NumParameters != 1 {
+		t.Errorf("outer has %d locals and %d parameters, want 2 and 1", outer.NumLocals, outer.NumParameters)
+	}
+}
+
+func TestCompileUndefinedNamesAsGlobals(t *testing.T) {
+	bytecode := compile(t, "let f = fn() { g() }; let g = fn() { 1 };")
+	if len(bytecode.Globals) != 2 || bytecode.Globals[0] != "f" || bytecode.Globals[1] != "g" {
+		t.Errorf("globals = %v, want [f g]", bytecode.Globals)
+	}
+}
+
+func TestCompileTooLong(t *testing.T) {
+	// Each x; is at least 3 bytes, OpGetLocal or OpGetGlobal and OpPop.
+	body := "let x = 0;" + strings.Repeat("x;", MAX_INSTRUCTIONS/3+1)
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{"if (true) {" + body + "} 0", "program too long"},
+		{"fn() {" + body + "}", "function too long"},
+	}
+	for _, tt := range tests {
+		c := New()
+		err := c.Compile(parser.New(lexer.New(tt.input)).ParseProgram())
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("got %v, want an error containing %q", err, tt.message)
+		}
+	}
+}
+
+func TestSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	local := NewEnclosedSymbolTable(global)
+	b := local.Define("b")
+	nested := NewEnclosedSymbolTable(local)
+	c := nested.Define("c")
+
+	if global.Define("a") != a {
+		t.Errorf("redefining a gave a new symbol")
+	}
+	tests := []struct {
+		table    *SymbolTable
+		name     string
+		expected Symbol
+	}{
+		{nested, "a", Symbol{Name: "a", Scope: GLOBAL_SCOPE, Index: 0}},
+		{nested, "b", Symbol{Name: "b", Scope: OUTER_SCOPE, Index: 0, Depth: 1}},
+		{nested, "c", c},
+		{local, "b", b},
+	}
+	for _, tt := range tests {
+		symbol, ok := tt.table.Resolve(tt.name)
+		if !ok || symbol != tt.expected {
+			t.Errorf("Resolve(%s) = %+v, %t, want %+v", tt.name, symbol, ok, tt.expected)
+		}
+	}
+	if _, ok := local.Resolve("c"); ok {
+		t.Errorf("c resolved outside its function")
+	}
+}
@@ -0,0 +1,314 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"interpreter/code"
+	"interpreter/object"
+	"math"
+)
+
+// A bytecode file (.mkc) starts with MAGIC and the big-endian uint16
+// FORMAT_VERSION. The rest is made of unsigned varints, signed varints for
+// integer constants, and strings and instructions prefixed with their
+// length:
+//
+//	globals    count, then each name
+//	constants  count, then each a tag and its value: 'i' an integer,
+//	           's' a string, 'f' a function: parameter count, local names,
+//	           instructions and line table
+//	main       instructions and line table
+//	source     the name of the source file
+//
+// A line table is its length and pairs of offset, as the distance from
+// the previous entry, and line.
+const (
+	MAGIC          = "\x7fMKC"
+	FORMAT_VERSION = 1
+)
+
+// ErrBytecode is the cause of the errors from decoding a malformed or
+// incompatible bytecode file.
+var ErrBytecode = errors.New("invalid bytecode")
+
+const (
+	TAG_INTEGER  = 'i'
+	TAG_STRING   = 's'
+	TAG_FUNCTION = 'f'
+)
+
+// IsBytecode reports whether data starts like a bytecode file.
+func IsBytecode(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(MAGIC))
+}
+
+// MarshalBinary encodes b in the bytecode file format.
+func (b *Bytecode) MarshalBinary() ([]byte, error) {
+	out := append([]byte(MAGIC), 0, 0)
+	binary.BigEndian.PutUint16(out[len(MAGIC):], FORMAT_VERSION)
+
+	out = binary.AppendUvarint(out, uint64(len(b.Globals)))
+	for _, name := range b.Globals {
+		out = appendString(out, name)
+	}
+	out = binary.AppendUvarint(out, uint64(len(b.Constants)))
+	for i, constant := range b.Constants {
+		switch constant := constant.(type) {
+		case *object.Integer:
+			out = append(out, TAG_INTEGER)
+			out = binary.AppendVarint(out, constant.Value)
+		case *object.String:
+			out = append(out, TAG_STRING)
+			out = appendString(out, constant.Value)
+		case *object.CompiledFunction:
+			out = append(out, TAG_FUNCTION)
+			out = binary.AppendUvarint(out, uint64(constant.NumParameters))
+			out = binary.AppendUvarint(out, uint64(len(constant.Locals)))
+			for _, name := range constant.Locals {
+				out = appendString(out, name)
+			}
+			out = appendString(out, string(constant.Instructions))
+			out = appendLines(out, constant.Lines)
+		default:
+			return nil, fmt.Errorf("constant %d: cannot encode %s", i, constant.Type())
+		}
+	}
+	out = appendString(out, string(b.Instructions))
+	out = appendLines(out, b.Lines)
+	out = appendString(out, b.Source)
+	return out, nil
+}
+
+func appendString(out []byte, s string) []byte {
+	out = binary.AppendUvarint(out, uint64(len(s)))
+	return append(out, s...)
+}
+
+func appendLines(out []byte, lines code.LineTable) []byte {
+	out = binary.AppendUvarint(out, uint64(len(lines)))
+	offset := 0
+	for _, line := range lines {
+		out = binary.AppendUvarint(out, uint64(line.Offset-offset))
+		out = binary.AppendUvarint(out, uint64(line.Line))
+		offset = line.Offset
+	}
+	return out
+}
+
+// UnmarshalBinary decodes a bytecode file into b, checking that its
+// instructions only refer to the constants, globals and locals it defines
+// so that the VM can run it safely.
+func (b *Bytecode) UnmarshalBinary(data []byte) error {
+	if !IsBytecode(data) {
+		return fmt.Errorf("%w: not a Monkey bytecode file", ErrBytecode)
+	}
+	data = data[len(MAGIC):]
+	if len(data) < 2 {
+		return fmt.Errorf("%w: truncated", ErrBytecode)
+	}
+	if version := binary.BigEndian.Uint16(data); version != FORMAT_VERSION {
+		return fmt.Errorf("%w: unsupported version %d, want %d", ErrBytecode, version, FORMAT_VERSION)
+	}
+	d := &decoder{data: data[2:]}
+
+	decoded := Bytecode{Globals: make([]string, d.count())}
+	for i := range decoded.Globals {
+		decoded.Globals[i] = d.string()
+	}
+	decoded.Constants = make([]object.Object, d.count())
+	for i := range decoded.Constants {
+		switch tag := d.byte(); tag {
+		case TAG_INTEGER:
+			decoded.Constants[i] = &object.Integer{Value: d.varint()}
+		case TAG_STRING:
+			decoded.Constants[i] = &object.String{Value: d.string()}
+		case TAG_FUNCTION:
+			fn := &object.CompiledFunction{NumParameters: d.count()}
+			fn.Locals = make([]string, d.count())
+			for j := range fn.Locals {
+				fn.Locals[j] = d.string()
+			}
+			fn.NumLocals = len(fn.Locals)
+			fn.Instructions = code.Instructions(d.string())
+			fn.Lines = d.lines()
+			decoded.Constants[i] = fn
+		default:
+			if d.err == nil {
+				d.err = fmt.Errorf("constant %d has unknown tag %q", i, tag)
+			}
+		}
+	}
+	decoded.Instructions = code.Instructions(d.string())
+	decoded.Lines = d.lines()
+	decoded.Source = d.string()
+	if d.err == nil && len(d.data) > 0 {
+		d.err = fmt.Errorf("%d trailing bytes", len(d.data))
+	}
+	if d.err != nil {
+		return fmt.Errorf("%w: %w", ErrBytecode, d.err)
+	}
+
+	if err := decoded.verify(decoded.Instructions, -1); err != nil {
+		return fmt.Errorf("%w: main: %w", ErrBytecode, err)
+	}
+	for i, constant := range decoded.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		if fn.NumParameters > fn.NumLocals || fn.NumLocals > MAX_LOCALS {
+			return fmt.Errorf("%w: constant %d: %d parameters and %d locals", ErrBytecode, i, fn.NumParameters, fn.NumLocals)
+		}
+		if err := decoded.verify(fn.Instructions, fn.NumLocals); err != nil {
+			return fmt.Errorf("%w: constant %d: %w", ErrBytecode, i, err)
+		}
+	}
+	*b = decoded
+	return nil
+}
+
+// verify checks that ins decodes, stays within its constants, globals and
+// numLocals locals, -1 for the main program, and cannot run past its end.
+func (b *Bytecode) verify(ins code.Instructions, numLocals int) error {
+	if len(ins) == 0 {
+		return errors.New("no instructions")
+	}
+	last := code.Opcode(0)
+	starts := make([]bool, len(ins))
+	var jumps []int
+	for i := 0; i < len(ins); {
+		starts[i] = true
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return fmt.Errorf("at %d: %w", i, err)
+		}
+		op := code.Opcode(ins[i])
+		if i+1+def.Width() > len(ins) {
+			return fmt.Errorf("at %d: %s is truncated", i, def.Name)
+		}
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		switch op {
+		case code.OpConstant:
+			if operands[0] >= len(b.Constants) {
+				return fmt.Errorf("at %d: constant %d out of range", i, operands[0])
+			}
+		case code.OpClosure:
+			if operands[0] >= len(b.Constants) {
+				return fmt.Errorf("at %d: constant %d out of range", i, operands[0])
+			}
+			if _, ok := b.Constants[operands[0]].(*object.CompiledFunction); !ok {
+				return fmt.Errorf("at %d: constant %d is not a function", i, operands[0])
+			}
+		case code.OpGetGlobal, code.OpSetGlobal, code.OpAssignGlobal:
+			if operands[0] >= len(b.Globals) {
+				return fmt.Errorf("at %d: global %d out of range", i, operands[0])
+			}
+		case code.OpGetLocal, code.OpSetLocal:
+			if operands[0] >= numLocals {
+				return fmt.Errorf("at %d: local %d out of range", i, operands[0])
+			}
+		case code.OpGetOuter, code.OpSetOuter:
+			// How far out the scopes reach depends on where closures are
+			// made, so the VM checks the rest.
+			if numLocals < 0 || operands[0] == 0 {
+				return fmt.Errorf("at %d: %s outside of a closure", i, def.Name)
+			}
+		case code.OpJump, code.OpJumpNotTruthy:
+			jumps = append(jumps, i)
+		}
+		last = op
+		i += 1 + read
+	}
+	for _, i := range jumps {
+		if target := int(code.ReadUint16(ins[i+1:])); target >= len(ins) || !starts[target] {
+			return fmt.Errorf("at %d: jump to %d is not to an instruction", i, target)
+		}
+	}
+	if last != code.OpReturnValue && last != code.OpReturn {
+		return errors.New("does not end with a return")
+	}
+	return nil
+}
+
+// decoder reads the parts of a bytecode file, keeping the first error.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = errors.New("truncated")
+	}
+	d.data = nil
+}
+
+func (d *decoder) byte() byte {
+	if len(d.data) == 0 {
+		d.fail()
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) int() int {
+	v := d.uvarint()
+	if v > math.MaxInt32 {
+		d.fail()
+		return 0
+	}
+	return int(v)
+}
+
+// count reads a length, which cannot exceed the bytes left since every
+// counted item takes at least one.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.data)) {
+		d.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) string() string {
+	n := d.count()
+	s := string(d.data[:n])
+	d.data = d.data[n:]
+	return s
+}
+
+func (d *decoder) lines() code.LineTable {
+	n := d.count()
+	var lines code.LineTable
+	offset := 0
+	for i := 0; i < n && d.err == nil; i++ {
+		offset += d.int()
+		lines = append(lines, code.Line{Offset: offset, Line: d.int()})
+	}
+	return lines
+}
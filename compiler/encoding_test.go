@@ -0,0 +1,99 @@
+package compiler
+
+import (
+	"errors"
+	"interpreter/code"
+	"interpreter/object"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodingRoundTrip(t *testing.T) {
+	bytecode := compile(t, "let greet = fn(name) {\n  \"hi \" + name\n};\nlet n = -42;\ngreet(\"ann\")")
+	bytecode.Source = "greet.mk"
+	data, err := bytecode.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsBytecode(data) {
+		t.Fatalf("encoded bytecode does not start with the magic header")
+	}
+	var decoded Bytecode
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, bytecode) {
+		t.Errorf("decoded bytecode differs:\nwant %+v\ngot  %+v", bytecode, &decoded)
+	}
+	fn := decoded.Constants[1].(*object.CompiledFunction)
+	if line := fn.Lines.Line(0); line != 2 {
+		t.Errorf("function body starts on line %d, want 2", line)
+	}
+}
+
+func TestDecodingErrors(t *testing.T) {
+	valid, err := compile(t, "let f = fn(x) { if (x) { 1 } else { 2 } }; f(true)").MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := len(MAGIC) + 2
+	corrupt := func(f func(data []byte) []byte) []byte {
+		return f(append([]byte(nil), valid...))
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		message string
+	}{
+		{"source", []byte("let a = 1;"), "not a Monkey bytecode file"},
+		{"version", corrupt(func(data []byte) []byte { data[len(MAGIC)+1] = 9; return data }), "unsupported version 9, want 1"},
+		{"trailing", append(append([]byte(nil), valid...), 0), "1 trailing bytes"},
+		{"constant tag", corrupt(func(data []byte) []byte {
+			// globals: 1, "f"; constants: 3, then the first tag
+			data[header+4] = 'x'
+			return data
+		}), "constant 0 has unknown tag 'x'"},
+	}
+	for _, tt := range tests {
+		var b Bytecode
+		err := b.UnmarshalBinary(tt.data)
+		if !errors.Is(err, ErrBytecode) || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.message)
+		}
+	}
+	for n := 0; n < len(valid); n++ {
+		var b Bytecode
+		if err := b.UnmarshalBinary(valid[:n]); !errors.Is(err, ErrBytecode) {
+			t.Fatalf("decoding the first %d bytes: got %v, want an error", n, err)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	b := &Bytecode{
+		Globals:   []string{"a"},
+		Constants: []object.Object{&object.Integer{Value: 1}},
+	}
+	tests := []struct {
+		ins       code.Instructions
+		numLocals int
+		message   string
+	}{
+		{concat(code.Make(code.OpConstant, 1), code.Make(code.OpReturnValue)), -1, "constant 1 out of range"},
+		{concat(code.Make(code.OpClosure, 0), code.Make(code.OpReturnValue)), -1, "constant 0 is not a function"},
+		{concat(code.Make(code.OpGetGlobal, 1), code.Make(code.OpReturnValue)), -1, "global 1 out of range"},
+		{concat(code.Make(code.OpGetLocal, 2), code.Make(code.OpReturnValue)), 2, "local 2 out of range"},
+		{concat(code.Make(code.OpGetOuter, 1, 0), code.Make(code.OpReturnValue)), -1, "OpGetOuter outside of a closure"},
+		{concat(code.Make(code.OpJump, 1), code.Make(code.OpReturn)), 0, "jump to 1 is not to an instruction"},
+		{concat(code.Make(code.OpTrue)), -1, "does not end with a return"},
+		{code.Instructions{byte(code.OpConstant), 0}, -1, "OpConstant is truncated"},
+		{code.Instructions{255}, -1, "opcode 255 undefined"},
+	}
+	for _, tt := range tests {
+		err := b.verify(tt.ins, tt.numLocals)
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%q: got %v, want an error containing %q", tt.ins, err, tt.message)
+		}
+	}
+}
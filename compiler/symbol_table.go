@@ -0,0 +1,81 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GLOBAL_SCOPE SymbolScope = "GLOBAL"
+	LOCAL_SCOPE  SymbolScope = "LOCAL"
+	// OUTER_SCOPE symbols are locals of an enclosing function, Depth
+	// functions out.
+	OUTER_SCOPE SymbolScope = "OUTER"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	Depth int
+}
+
+// SymbolTable assigns slots to the names defined in a function, or to
+// globals when it has no Outer table.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store map[string]Symbol
+	names []string
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define returns the symbol for name in s, giving it the next slot if it
+// is not defined there yet.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok {
+		return symbol
+	}
+	symbol := Symbol{Name: name, Index: len(s.names), Scope: LOCAL_SCOPE}
+	if s.Outer == nil {
+		symbol.Scope = GLOBAL_SCOPE
+	}
+	s.store[name] = symbol
+	s.names = append(s.names, name)
+	return symbol
+}
+
+// Resolve looks name up in s and then in the tables enclosing it.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	depth := 0
+	for t := s; t != nil; t = t.Outer {
+		if symbol, ok := t.store[name]; ok {
+			if depth > 0 && symbol.Scope == LOCAL_SCOPE {
+				symbol.Scope = OUTER_SCOPE
+				symbol.Depth = depth
+			}
+			return symbol, true
+		}
+		depth++
+	}
+	return Symbol{}, false
+}
+
+// Global returns the outermost table, which holds the globals.
+func (s *SymbolTable) Global() *SymbolTable {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s
+}
+
+// Names returns the names defined in s, indexed by slot.
+func (s *SymbolTable) Names() []string {
+	return s.names
+}
@@ -0,0 +1,96 @@
+// Package evaltest holds test inputs shared by the backends that must
+// behave like the evaluator.
+package evaltest
+
+import (
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+)
+
+// Corpus holds the inputs of the evaluator tests and more, which other
+// backends must evaluate to the same values and errors.
+var Corpus = []string{
+	"1", "2", "-10", "5+5", "2 * 2 + 2 * 3", "-1*3 + 2 * (1 - 5 ) + 13",
+	"50 / 2 * 2 + 10", "(5 + 10 * 2 + 15 / 3) * 2 + -10",
+	"true", "false", "1<2", "1>2", "1>1", "1<1", "1==1", "1==2", "1!=2", "1!=1",
+	"(1 > 2) == false", "(1 < 2) == true", "true == true", "1 == true", "true != 1",
+	"!true", "!!true", "!5", "!false", "!!false", "!0", `!""`,
+	"if (true) { 10 }", "if (false) { 10 }", "if (1) { 10 }", "if (1 < 2) { 10 }",
+	"if (1 > 2) { 10 }", "if (1 > 2) { 10 } else { 20 }", "if (1 < 2) { 10 } else { 20 }",
+	"if (true) { let a = 1; }", "if (true) {}",
+	"return 10+2;", "return 4+2;9;", "11;return 11-5*2;10;", "if(false){return 1;}return 2;",
+	"if (true) { if (true) { return 10; } return 1; }",
+	"let x = if (true) { return 5; }; 10", "let x = 1; x = if (true) { return 5; }; x",
+	"1 + if (true) { return 5; }", "-if (true) { return 5; }", "if (if (true) { return 5; }) { 1 }",
+	"let f = fn(x) { x }; f(if (true) { return 5; })",
+	"let f = fn() { let x = if (true) { return 5; }; 10 }; f() + 1",
+	"let f = fn() { for (if (true) { return 5; }) {} }; f()",
+	"1 + true;", "1 + true;1;", "-true", "true + false;", "1;true + false;5;",
+	"if(true){true + false;}", "foobar", "let a = b;", "a = 1;",
+	"let f = fn(x) { x }; f(1, 2)", "1(2)", "true()", "let f = fn(x) { x }; f(-true)",
+	"a * 2", "2 * a", "-a", "if (a) { 1 }", "let f = fn() { return a; }; f()",
+	"1 / 0", "let f = fn(x) { 10 / x }; f(0)", "fn() {} + 1", `"a" - "b"`, `"a" + 1`,
+	"let a = 5; a;", "let a = 5 * 5; a;", "let a = 5; let b = a; let c = a + b + 5; c;",
+	"let a = 1;", "let a = 1; let a = 2; a",
+	"let a = 5; a = 6; a;", "let a = 5; a = a * 2; a;", "let a = 1; let b = 2; a = b; b = 3; a + b;",
+	"let identity = fn(x) { x; }; identity(5);", "let identity = fn(x) { return x; }; identity(5);",
+	"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", "fn(x) { x; }(5)",
+	"let f = fn() { return 1; 2; }; f() + f();", "fn() {}()", "let f = fn() { let a = 1; }; f()",
+	"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(3);",
+	"let x = 10; let f = fn(x) { x }; f(1) + x;",
+	"let count = 0; let inc = fn() { count = count + 1; }; inc(); inc(); count;",
+	"let fact = fn(n) { if (n < 2) { return 1; } n * fact(n - 1) }; fact(5);",
+	"let counter = fn() { let c = 0; fn() { c = c + 1; c } }; let next = counter(); next(); next(); next()",
+	"let f = fn() { let c = 0; let inc = fn() { c = c + 1; }; inc(); inc(); c }; f()",
+	"let f = fn(a) { fn(b) { fn(c) { a + b + c } } }; f(1)(2)(3)",
+	"let f = fn() { let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(10) }; f()",
+	"let f = fn() { g() }; let g = fn() { 7 }; f()",
+	"let f = fn() { g() }; f()",
+	`"hello"`, `"hello" + " " + "world"`, `let greet = fn(name) { "hi " + name }; greet("ann")`,
+	`"a" == "a"`, `"a" != "a"`, `"a" == "b"`,
+	"let i = 0; let s = 0; for (i < 5) { s = s + i; i = i + 1; } s", "let i = 0; for (false) { i = 1; } i",
+	"let f = fn() { let i = 0; for (true) { if (i > 2) { return i; } i = i + 1; } }; f()",
+	"let f = fn() { for (false) {} }; f()", "for (false) {}",
+	"let i = 0; for (true) { if (i == 3) { return i * 10; } i = i + 1; }",
+	"for (1 + true) {}", "let i = 0; for (i < 3) { i = i + x; }",
+	"let f = fn(n, acc) { if (n == 0) { acc } else { f(n - 1, acc + n) } }; f(50000, 0)",
+	"let f = fn(n) { if (n == 0) { return 7; } return f(n - 1); }; f(50000)",
+	"let f = fn(n) { for (true) { if (n == 0) { return 7; } return f(n - 1); } }; f(50000)",
+	"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(50001)",
+	"let f = fn(n) { n }; let x = if (true) { return f(3); }; x",
+	"let f = fn(n) { n }; let g = fn() { let x = if (true) { return f(3); }; x + 1 }; g()",
+	"let f = fn(n) { if (n == 0) { -true } else { f(n - 1) } }; f(20000)",
+	`len("four")`, `len(1)`, `len("a", "b")`, `let f = fn(s) { len(s) * 2 }; f("abc")`,
+}
+
+// Builtins holds a stand-in for the interpreter's builtins, which the
+// inputs of Corpus may call.
+var Builtins = map[string]object.Object{
+	"len": &object.Builtin{Name: "len", Fn: func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return &object.Error{Message: "wrong number of arguments"}
+		}
+		s, ok := args[0].(*object.String)
+		if !ok {
+			return &object.Error{Message: "len: want a string"}
+		}
+		return &object.Integer{Value: int64(len(s.Value))}
+	}},
+}
+
+// Eval evaluates input with the evaluator, given Builtins, as the result
+// other backends should match.
+func Eval(input string) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment()
+	for name, builtin := range Builtins {
+		env.Set(name, builtin)
+	}
+	result := evaluator.Eval(program, env)
+	if result == nil {
+		return object.NULL
+	}
+	return result
+}
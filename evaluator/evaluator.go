@@ -1,58 +1,308 @@
 package evaluator
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"interpreter/ast"
 	"interpreter/object"
 )
 
 var (
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{}
-	NULL  = &object.Null{}
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
+	NULL  = object.NULL
 )
 
-func Eval(node ast.Node) object.Object {
+// DEFAULT_MAX_DEPTH bounds nested function calls, keeping runaway
+// recursion well short of exhausting the Go stack.
+const DEFAULT_MAX_DEPTH = 10000
+
+// Causes of the errors that end an evaluation early, available from the
+// Err field of the resulting *object.Error.
+var (
+	ErrCanceled   = errors.New("evaluation canceled")
+	ErrStepLimit  = errors.New("step limit exceeded")
+	ErrDepthLimit = errors.New("maximum recursion depth exceeded")
+)
+
+// Evaluator evaluates syntax trees within the limits it is configured
+// with. It keeps count of the steps taken, so it should not be shared
+// between concurrent evaluations.
+type Evaluator struct {
+	// MaxSteps caps the number of nodes evaluated; zero means no limit.
+	MaxSteps int
+	// MaxDepth caps nested function calls, which tail calls are not;
+	// zero means DEFAULT_MAX_DEPTH.
+	MaxDepth int
+	// Memory, if set, accounts the bytes bound by the evaluation and
+	// enforces its limit.
+	Memory *Memory
+
+	ctx   context.Context
+	steps int
+	depth int
+	frame frame
+}
+
+// New returns an Evaluator that stops with an ErrCanceled error once ctx
+// is done. ctx is checked before every top-level statement, loop iteration
+// and function call.
+func New(ctx context.Context) *Evaluator {
+	return &Evaluator{ctx: ctx}
+}
+
+// Steps returns the number of nodes evaluated so far.
+func (e *Evaluator) Steps() int {
+	return e.steps
+}
+
+// Eval evaluates node in env without limits other than DEFAULT_MAX_DEPTH.
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New(context.Background()).Eval(node, env)
+}
+
+// ApplyFunction calls fn, a function or builtin, with args, without limits
+// other than DEFAULT_MAX_DEPTH.
+func ApplyFunction(fn object.Object, args []object.Object) object.Object {
+	return New(context.Background()).ApplyFunction(fn, args)
+}
+
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	if err := e.step(); err != nil {
+		return err
+	}
 	switch node := node.(type) {
 	case *ast.IntegerLiteral:
-		return &object.Integer{
-			Value: node.Value,
-		}
+		return object.NewInteger(node.Value)
 	case *ast.Boolean:
 		return boolToBooleanObject(node.Value)
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
 	case *ast.PrefixExpression:
-		right := Eval(node.Right)
-		return evalPrefixExpression(node.Operator, right)
+		right := e.Eval(node.Right, env)
+		if stops(right) {
+			return right
+		}
+		result := evalPrefixExpression(node.Operator, right)
+		if err := e.transient(result); err != nil {
+			return err
+		}
+		return result
 	case *ast.InfixExpression:
-		left := Eval(node.Left)
-		right := Eval(node.Right)
-		return evalInfixExpression(left, node.Operator, right)
+		left := e.Eval(node.Left, env)
+		if stops(left) {
+			return left
+		}
+		right := e.Eval(node.Right, env)
+		if stops(right) {
+			return right
+		}
+		result := evalInfixExpression(left, node.Operator, right)
+		if err := e.transient(result); err != nil {
+			return err
+		}
+		return result
 	case *ast.IfExpression:
-		return evalIfExpression(node)
+		return e.evalIfExpression(node, env)
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env)
 	case *ast.BlockStatement:
-		return evalStatements(node.Statements)
+		return e.evalStatements(node.Statements, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue)
+		val := e.evalTail(node.ReturnValue, env)
+		if stops(val) {
+			return val
+		}
 		return &object.ReturnValue{Value: val}
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression)
+		return e.Eval(node.Expression, env)
+	case *ast.LetStatement:
+		val := e.Eval(node.Value, env)
+		if stops(val) {
+			return val
+		}
+		old, rebind := env.Local(node.Name.Value)
+		if err := e.bind(env, node.Name.Value, val, old, rebind); err != nil {
+			return err
+		}
+		env.Set(node.Name.Value, val)
+		return nil
+	case *ast.AssignmentStatement:
+		return e.evalAssignmentStatement(node, env)
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
+	case *ast.CallExpression:
+		function := e.Eval(node.Function, env)
+		if stops(function) {
+			return function
+		}
+		args := e.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && stops(args[0]) {
+			return args[0]
+		}
+		return e.ApplyFunction(function, args)
 	case *ast.Program:
-		return evalProgram(node)
+		return e.evalProgram(node, env)
 	}
 	return createError("invalid node: got %T", node)
 }
 
-func evalIfExpression(ie *ast.IfExpression) object.Object {
-	condition := Eval(ie.Condition)
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	val, ok := env.Get(node.Value)
+	if !ok {
+		return createError("identifier not found: %s", node.Value)
+	}
+	return val
+}
+
+func (e *Evaluator) evalAssignmentStatement(node *ast.AssignmentStatement, env *object.Environment) object.Object {
+	scope := env.Scope(node.Ident.Value)
+	if scope == nil {
+		return createError("identifier not found: %s", node.Ident.Value)
+	}
+	val := e.Eval(node.Value, env)
+	if stops(val) {
+		return val
+	}
+	old, _ := scope.Local(node.Ident.Value)
+	if err := e.bind(scope, node.Ident.Value, val, old, true); err != nil {
+		return err
+	}
+	scope.Set(node.Ident.Value, val)
+	return nil
+}
+
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+	for _, exp := range exps {
+		evaluated := e.Eval(exp, env)
+		if stops(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+	return result
+}
+
+// step counts a node evaluated, returning an error once there are more
+// than MaxSteps.
+func (e *Evaluator) step() *object.Error {
+	e.steps++
+	if e.MaxSteps > 0 && e.steps > e.MaxSteps {
+		return StepLimitError(e.MaxSteps)
+	}
+	return nil
+}
+
+// ApplyFunction calls fn, a function or builtin, with args. The calls fn
+// makes in tail position are made here in turn, once the call making each
+// has returned, so they take neither Go stack nor recursion depth.
+func (e *Evaluator) ApplyFunction(fn object.Object, args []object.Object) object.Object {
+	for {
+		result := e.apply(fn, args)
+		call, ok := result.(*tailCall)
+		if !ok {
+			return result
+		}
+		fn, args = call.fn, call.args
+	}
+}
+
+// apply makes one call, returning a *tailCall if fn ends with one.
+func (e *Evaluator) apply(fn object.Object, args []object.Object) object.Object {
+	if err := e.ctx.Err(); err != nil {
+		return CanceledError(err)
+	}
+	switch fn := fn.(type) {
+	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return createError("wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+		}
+		maxDepth := e.MaxDepth
+		if maxDepth <= 0 {
+			maxDepth = DEFAULT_MAX_DEPTH
+		}
+		if e.depth >= maxDepth {
+			return DepthLimitError(maxDepth)
+		}
+		e.depth++
+		defer func() { e.depth-- }()
+
+		env := object.NewEnclosedEnvironment(fn.Env)
+		caller, err := e.enter(env)
+		if err != nil {
+			return err
+		}
+		result := e.callFunction(fn, env, args)
+		e.leave(caller, result)
+		return result
+	case *object.Builtin:
+		result := fn.Fn(args...)
+		if err := e.transient(result); err != nil {
+			return err
+		}
+		return result
+	}
+	return createError("not a function: %s", fn.Type())
+}
+
+func (e *Evaluator) callFunction(fn *object.Function, env *object.Environment, args []object.Object) object.Object {
+	for i, param := range fn.Parameters {
+		if err := e.bind(env, param.Value, args[i], nil, false); err != nil {
+			return err
+		}
+		env.Set(param.Value, args[i])
+	}
+	evaluated := e.evalTailBlock(fn.Body, env)
+	if returnValue, ok := evaluated.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+	if evaluated == nil {
+		return NULL
+	}
+	return evaluated
+}
+
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.Eval(ie.Condition, env)
+	if stops(condition) {
+		return condition
+	}
 	if IsTruthy(condition) {
-		return Eval(ie.Consequence)
+		return e.Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative)
+		return e.Eval(ie.Alternative, env)
 	} else {
 		return NULL
 	}
 }
 
+// evalForStatement runs the block while the condition is truthy. A return
+// or an error inside the block ends the loop and is passed on.
+func (e *Evaluator) evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	for {
+		if err := e.ctx.Err(); err != nil {
+			return CanceledError(err)
+		}
+		condition := e.Eval(fs.Condition, env)
+		if stops(condition) {
+			return condition
+		}
+		if !IsTruthy(condition) {
+			return nil
+		}
+		result := e.Eval(fs.Block, env)
+		if result != nil {
+			if rt := result.Type(); rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+}
+
 func IsTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
@@ -66,19 +316,33 @@ func IsTruthy(obj object.Object) bool {
 	}
 }
 
+// EvalInfix applies the infix operator to left and right as the
+// evaluator does, for other backends to share its semantics.
+func EvalInfix(left object.Object, operator string, right object.Object) object.Object {
+	return evalInfixExpression(left, operator, right)
+}
+
+// EvalPrefix applies the prefix operator to right as the evaluator does.
+func EvalPrefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
 func evalInfixExpression(left object.Object, operator string, right object.Object) object.Object {
 	if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
 		l := left.(*object.Integer)
 		r := right.(*object.Integer)
 		switch operator {
 		case "+":
-			return &object.Integer{Value: l.Value + r.Value}
+			return object.NewInteger(l.Value + r.Value)
 		case "-":
-			return &object.Integer{Value: l.Value - r.Value}
+			return object.NewInteger(l.Value - r.Value)
 		case "*":
-			return &object.Integer{Value: l.Value * r.Value}
+			return object.NewInteger(l.Value * r.Value)
 		case "/":
-			return &object.Integer{Value: l.Value / r.Value}
+			if r.Value == 0 {
+				return createError("division by zero")
+			}
+			return object.NewInteger(l.Value / r.Value)
 		case ">":
 			return boolToBooleanObject(l.Value > r.Value)
 		case "<":
@@ -89,6 +353,18 @@ func evalInfixExpression(left object.Object, operator string, right object.Objec
 			return boolToBooleanObject(l.Value != r.Value)
 		}
 	}
+	if left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ {
+		l := left.(*object.String)
+		r := right.(*object.String)
+		switch operator {
+		case "+":
+			return &object.String{Value: l.Value + r.Value}
+		case "==":
+			return boolToBooleanObject(l.Value == r.Value)
+		case "!=":
+			return boolToBooleanObject(l.Value != r.Value)
+		}
+	}
 	switch operator {
 	case "==":
 		return boolToBooleanObject(left == right)
@@ -118,9 +394,7 @@ func evalMinusOperatorExpression(right object.Object) object.Object {
 	if !ok || right.Type() != object.INTEGER_OBJ {
 		return createError("unknown operator: -%s", right.Type())
 	}
-	return &object.Integer{
-		Value: -value.Value,
-	}
+	return object.NewInteger(-value.Value)
 }
 
 func evalBangOperatorExpression(right object.Object) object.Object {
@@ -136,10 +410,10 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalStatements(stmts []ast.Statement) object.Object {
+func (e *Evaluator) evalStatements(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 	for _, stmt := range stmts {
-		result = Eval(stmt)
+		result = e.Eval(stmt, env)
 		if nil != result {
 			resultType := result.Type()
 			if resultType == object.RETURN_VALUE_OBJ || resultType == object.ERROR_OBJ {
@@ -150,12 +424,15 @@ func evalStatements(stmts []ast.Statement) object.Object {
 	return result
 }
 
-func evalProgram(program *ast.Program) object.Object {
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 	for _, stmt := range program.Statements {
-		result = Eval(stmt)
+		if err := e.ctx.Err(); err != nil {
+			return CanceledError(err)
+		}
+		result = e.Eval(stmt, env)
 		if returnValue, ok := result.(*object.ReturnValue); ok {
-			return returnValue.Value
+			return e.complete(returnValue.Value)
 		}
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -168,10 +445,22 @@ func evalProgram(program *ast.Program) object.Object {
 }
 
 func boolToBooleanObject(value bool) *object.Boolean {
-	if value {
-		return TRUE
+	return object.NativeBool(value)
+}
+
+// stops reports whether obj ends the evaluation of what contains it: an
+// error, or the value of a return statement, which ends the call or
+// program wherever in an expression it runs.
+func stops(obj object.Object) bool {
+	if obj == nil {
+		return false
 	}
-	return FALSE
+	t := obj.Type()
+	return t == object.ERROR_OBJ || t == object.RETURN_VALUE_OBJ
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
 }
 
 func createError(formattedMessage string, args ...interface{}) *object.Error {
@@ -179,3 +468,7 @@ func createError(formattedMessage string, args ...interface{}) *object.Error {
 		Message: fmt.Sprintf(formattedMessage, args...),
 	}
 }
+
+func limitError(err error, formattedMessage string, args ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(formattedMessage, args...), Err: err}
+}
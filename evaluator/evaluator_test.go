@@ -1,10 +1,14 @@
 package evaluator
 
 import (
+	"context"
+	"errors"
 	"interpreter/lexer"
 	"interpreter/object"
 	"interpreter/parser"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -168,6 +172,10 @@ func TestReturnStatement(t *testing.T) {
 					}
 				return 1;
 				}`, 10},
+		{"let x = if (true) { return 5; }; 10", 5},
+		{"1 + if (true) { return 5; }", 5},
+		{"let f = fn(x) { x }; f(if (true) { return 5; })", 5},
+		{"let f = fn() { let x = if (true) { return 5; }; 10 }; f() + 1", 6},
 	}
 	for _, test := range tests {
 		eval := testEval(test.input)
@@ -190,6 +198,18 @@ func TestErrorHandling(t *testing.T) {
 		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
 		{"1;true + false;5;", "unknown operator: BOOLEAN + BOOLEAN"},
 		{"if(true){true + false;}", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"foobar", "identifier not found: foobar"},
+		{"let a = b;", "identifier not found: b"},
+		{"a = 1;", "identifier not found: a"},
+		{"let f = fn(x) { x }; f(1, 2)", "wrong number of arguments: want=1, got=2"},
+		{"1(2)", "not a function: INTEGER"},
+		{"let f = fn(x) { x }; f(-true)", "unknown operator: -BOOLEAN"},
+		{"a * 2", "identifier not found: a"},
+		{"2 * a", "identifier not found: a"},
+		{"-a", "identifier not found: a"},
+		{"if (a) { 1 }", "identifier not found: a"},
+		{"let f = fn() { return a; }; f()", "identifier not found: a"},
+		{"1 / 0", "division by zero"},
 	}
 	for _, test := range tests {
 		evaluated := testEval(test.input)
@@ -204,6 +224,236 @@ func TestErrorHandling(t *testing.T) {
 
 }
 
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+	for _, test := range tests {
+		testIntegerObject(t, testEval(test.input), test.expected)
+	}
+}
+
+func TestAssignmentStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a = 6; a;", 6},
+		{"let a = 5; a = a * 2; a;", 10},
+		{"let a = 1; let b = 2; a = b; b = 3; a + b;", 5},
+	}
+	for _, test := range tests {
+		testIntegerObject(t, testEval(test.input), test.expected)
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	evaluated := testEval("fn(x) { x + 2; };")
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("Expected *object.Function got %T (%v)", evaluated, evaluated)
+	}
+	if len(fn.Parameters) != 1 || fn.Parameters[0].String() != "x" {
+		t.Fatalf("Unexpected function parameters %v", fn.Parameters)
+	}
+	if fn.Body.String() != "(x + 2)" {
+		t.Fatalf("Expected function body %q got %q", "(x + 2)", fn.Body.String())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let f = fn() { return 1; 2; }; f() + f();", 2},
+	}
+	for _, test := range tests {
+		testIntegerObject(t, testEval(test.input), test.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(3);", 5},
+		{"let x = 10; let f = fn(x) { x }; f(1) + x;", 11},
+		{"let count = 0; let inc = fn() { count = count + 1; }; inc(); inc(); count;", 2},
+		{"let fact = fn(n) { if (n < 2) { return 1; } n * fact(n - 1) }; fact(5);", 120},
+	}
+	for _, test := range tests {
+		testIntegerObject(t, testEval(test.input), test.expected)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// far deeper than DEFAULT_MAX_DEPTH
+		{"let countdown = fn(n) { if (n == 0) { return 0; } countdown(n - 1) }; countdown(1000000)", 0},
+		{"let sum = fn(n, acc) { if (n == 0) { acc } else { return sum(n - 1, acc + n); } }; sum(100000, 0)", 5000050000},
+		{"let even = fn(n) { if (n == 0) { 1 } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { 0 } else { even(n - 1) } }; even(100001)", 0},
+		{"let f = fn(n) { for (true) { if (n == 0) { return 7; } return f(n - 1); } }; f(50000)", 7},
+		{"let f = fn(n) { if (n == 0) { return 3; } f(n - 1) }; return f(20000);", 3},
+		{"let adder = fn(x) { fn(y) { x + y } }; let call = fn(g, v) { g(v) }; call(adder(40), 2)", 42},
+		// the argument is evaluated before the caller's frame is left
+		{"let id = fn(x) { x }; let f = fn(n) { let m = n * 2; id(m + 1) }; f(5)", 11},
+	}
+	for _, test := range tests {
+		testIntegerObject(t, testEval(test.input), test.expected)
+	}
+
+	// Calls that are not in tail position still count towards the depth.
+	evaluated := testEval("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(1000000)")
+	if errorObj, ok := evaluated.(*object.Error); !ok || !errors.Is(errorObj.Err, ErrDepthLimit) {
+		t.Errorf("expected a depth limit error, got %s", evaluated.Inspect())
+	}
+	evaluated = testEval("let f = fn(n) { if (n == 0) { -true } else { f(n - 1) } }; f(20000)")
+	if errorObj, ok := evaluated.(*object.Error); !ok || errorObj.Message != "unknown operator: -BOOLEAN" {
+		t.Errorf("expected an operator error, got %s", evaluated.Inspect())
+	}
+}
+
+func TestEmptyFunctionReturnsNull(t *testing.T) {
+	testNullObject(t, testEval("fn() {}()"))
+	testNullObject(t, testEval("let f = fn() { let a = 1; }; f()"))
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"hello"`, "hello"},
+		{`"hello" + " " + "world"`, "hello world"},
+		{`let greet = fn(name) { "hi " + name }; greet("ann")`, "hi ann"},
+	}
+	for _, test := range tests {
+		str, ok := testEval(test.input).(*object.String)
+		if !ok || str.Value != test.expected {
+			t.Errorf("%s: expected %q got %v", test.input, test.expected, str)
+		}
+	}
+	testBooleanObject(t, testEval(`"a" == "a"`), true)
+	testBooleanObject(t, testEval(`"a" != "a"`), false)
+	testBooleanObject(t, testEval(`"a" == "b"`), false)
+	errorObj, ok := testEval(`"a" - "b"`).(*object.Error)
+	if !ok || errorObj.Message != "unknown operator: STRING - STRING" {
+		t.Errorf("expected unknown operator error, got %v", errorObj)
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let i = 0; let s = 0; for (i < 5) { s = s + i; i = i + 1; } s", 10},
+		{"let i = 0; for (false) { i = 1; } i", 0},
+		{"let f = fn() { let i = 0; for (true) { if (i > 2) { return i; } i = i + 1; } }; f()", 3},
+	}
+	for _, test := range tests {
+		testIntegerObject(t, testEval(test.input), test.expected)
+	}
+	testNullObject(t, testEval("let f = fn() { for (false) {} }; f()"))
+}
+
+func TestLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		maxSteps int
+		maxDepth int
+		expected error
+		message  string
+	}{
+		{"for (true) {}", expired, 0, 0, context.DeadlineExceeded, "evaluation canceled: context deadline exceeded"},
+		{"1", canceled, 0, 0, context.Canceled, "evaluation canceled: context canceled"},
+		{"let f = fn() { for (true) {} }; f()", expired, 0, 0, ErrCanceled, "evaluation canceled: context deadline exceeded"},
+		{"for (true) {}", context.Background(), 1000, 0, ErrStepLimit, "step limit of 1000 exceeded"},
+		{"let f = fn(n) { 1 + f(n + 1) }; f(0)", context.Background(), 0, 50, ErrDepthLimit, "maximum recursion depth of 50 exceeded"},
+		{"let f = fn(n) { 1 + f(n + 1) }; f(0)", context.Background(), 0, 0, ErrDepthLimit, "maximum recursion depth of 10000 exceeded"},
+	}
+	for _, test := range tests {
+		e := New(test.ctx)
+		e.MaxSteps = test.maxSteps
+		e.MaxDepth = test.maxDepth
+		evaluated := e.Eval(parser.New(lexer.New(test.input)).ParseProgram(), object.NewEnvironment())
+		errorObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected *object.Error got %T (%v)", test.input, evaluated, evaluated)
+			continue
+		}
+		if !errors.Is(errorObj.Err, test.expected) {
+			t.Errorf("%s: expected error matching %v got %v", test.input, test.expected, errorObj.Err)
+		}
+		if errorObj.Message != test.message {
+			t.Errorf("%s: expected message %q got %q", test.input, test.message, errorObj.Message)
+		}
+	}
+}
+
+func TestStepsWithinLimit(t *testing.T) {
+	e := New(context.Background())
+	e.MaxSteps = 100
+	evaluated := e.Eval(parser.New(lexer.New("let a = 1; a + 2")).ParseProgram(), object.NewEnvironment())
+	testIntegerObject(t, evaluated, 3)
+	if e.Steps() == 0 || e.Steps() > 100 {
+		t.Errorf("unexpected step count %d", e.Steps())
+	}
+}
+
+func TestApplyWithin(t *testing.T) {
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New("let deep = fn(n) { if (n == 0) { 0 } else { 1 + deep(n - 1) } };")).ParseProgram(), env)
+	deep, _ := env.Get("deep")
+
+	tests := []struct {
+		n        int64
+		steps    int
+		depth    int
+		expected string
+	}{
+		{5, 0, 0, "5"},
+		{5, 0, 46, "maximum recursion depth of 50 exceeded"},
+		{5, 990, 0, "step limit of 1000 exceeded"},
+	}
+	for _, test := range tests {
+		result, steps := ApplyWithin(context.Background(), deep, []object.Object{&object.Integer{Value: test.n}}, 1000, test.steps, 50, test.depth)
+		got := result.Inspect()
+		if err, ok := result.(*object.Error); ok {
+			got = err.Message
+		}
+		if got != test.expected {
+			t.Errorf("ApplyWithin(steps %d, depth %d) = %s, want %s", test.steps, test.depth, got, test.expected)
+		}
+		if steps <= test.steps {
+			t.Errorf("ApplyWithin(steps %d, depth %d) counted %d steps", test.steps, test.depth, steps)
+		}
+	}
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
 	if obj != NULL {
 		t.Errorf("Object is not null, got %T (%v)", obj, obj)
@@ -216,5 +466,48 @@ func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
-	return Eval(program)
+	env := object.NewEnvironment()
+	return Eval(program, env)
+}
+
+// TestConcurrentEval evaluates one syntax tree from many goroutines, each
+// with its own environment and evaluator; run with -race.
+func TestConcurrentEval(t *testing.T) {
+	program := parser.New(lexer.New("let f = fn(x) { let i = 0; for (i < x) { i = i + 1; } i }; f(n)")).ParseProgram()
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(n int64) {
+			defer wg.Done()
+			env := object.NewEnvironment()
+			env.Set("n", &object.Integer{Value: n})
+			e := New(context.Background())
+			e.MaxSteps = 100000
+			e.Memory = &Memory{}
+			testIntegerObject(t, e.Eval(program, env), n)
+		}(int64(i))
+	}
+	wg.Wait()
+}
+
+// arithmeticScripts are dominated by integer arithmetic on small values.
+var arithmeticScripts = []struct {
+	name   string
+	script string
+}{
+	{"fib", "let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(18)"},
+	{"nested loops", "let t = 0; let i = 0; for (i < 100) { let j = 0; for (j < 100) { t = (t + i * j) / 2 - j; j = j + 1; } i = i + 1; } t"},
+	{"polynomial", "let p = fn(x) { 3 * x * x - 2 * x + 7 }; let i = -200; let s = 0; for (i < 200) { s = p(i) - s; i = i + 1; } s"},
+}
+
+func BenchmarkArithmetic(b *testing.B) {
+	for _, bm := range arithmeticScripts {
+		program := parser.New(lexer.New(bm.script)).ParseProgram()
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				Eval(program, object.NewEnvironment())
+			}
+		})
+	}
 }
@@ -0,0 +1,41 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"interpreter/object"
+)
+
+// CanceledError returns the error ending an evaluation whose context is
+// done, err being the context's error.
+func CanceledError(err error) *object.Error {
+	return &object.Error{
+		Message: fmt.Sprintf("%s: %s", ErrCanceled, err),
+		Err:     fmt.Errorf("%w: %w", ErrCanceled, err),
+	}
+}
+
+// StepLimitError returns the error ending an evaluation that took more
+// than maxSteps steps.
+func StepLimitError(maxSteps int) *object.Error {
+	return limitError(ErrStepLimit, "step limit of %d exceeded", maxSteps)
+}
+
+// DepthLimitError returns the error ending an evaluation that nested more
+// than maxDepth function calls.
+func DepthLimitError(maxDepth int) *object.Error {
+	return limitError(ErrDepthLimit, "maximum recursion depth of %d exceeded", maxDepth)
+}
+
+// ApplyWithin calls fn for another backend running under ctx within its
+// limits: maxSteps, of which steps are taken, and maxDepth, of which depth
+// calls are in progress. It returns the result, with errors naming those
+// limits, and the steps taken by then.
+func ApplyWithin(ctx context.Context, fn object.Object, args []object.Object, maxSteps, steps, maxDepth, depth int) (object.Object, int) {
+	e := New(ctx)
+	e.MaxSteps = maxSteps
+	e.MaxDepth = maxDepth
+	e.steps = steps
+	e.depth = depth
+	return e.ApplyFunction(fn, args), e.steps
+}
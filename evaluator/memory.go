@@ -0,0 +1,192 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"interpreter/object"
+)
+
+// ErrMemoryLimit is the cause of errors ending an evaluation that crossed
+// its Memory limit.
+var ErrMemoryLimit = errors.New("memory limit exceeded")
+
+// Approximate sizes in bytes, as allocated on a 64-bit platform.
+const (
+	INTEGER_SIZE  = 16
+	STRING_SIZE   = 16
+	ARRAY_SIZE    = 24
+	POINTER_SIZE  = 8
+	HASH_SIZE     = 48
+	PAIR_SIZE     = 56
+	FUNCTION_SIZE = 64
+	ENV_SIZE      = 64
+	BINDING_SIZE  = 32
+)
+
+// Memory accounts the approximate bytes held by the values bound in the
+// environments of an evaluation, and by the environments of the calls in
+// progress. A call's bytes are released when it returns, unless the
+// result is a closure over its environment. Values an operator or a
+// builtin creates count towards Peak, and against Limit, even when they
+// are not bound.
+//
+// A Memory may be shared by consecutive evaluations over the same
+// environment, as an interpreter does across runs.
+type Memory struct {
+	// Limit is the byte quota; zero means no limit.
+	Limit int64
+	Used  int64
+	Peak  int64
+}
+
+// reserve adds n bytes to the usage, failing if that crosses the limit.
+func (m *Memory) reserve(n int64) *object.Error {
+	if m.Limit > 0 && m.Used+n > m.Limit {
+		return limitError(ErrMemoryLimit, "memory limit of %d bytes exceeded", m.Limit)
+	}
+	m.Used += n
+	if m.Used > m.Peak {
+		m.Peak = m.Used
+	}
+	return nil
+}
+
+// Bind accounts a host binding value to name in env, replacing whatever
+// was bound there. It fails with an error wrapping ErrMemoryLimit, leaving
+// the usage unchanged, if the binding would cross the limit.
+func (m *Memory) Bind(env *object.Environment, name string, value object.Object) error {
+	delta := SizeOf(value) + BINDING_SIZE
+	if old, ok := env.Local(name); ok {
+		delta -= SizeOf(old) + BINDING_SIZE
+	}
+	if err := m.reserve(delta); err != nil {
+		return fmt.Errorf("%w: %d bytes", ErrMemoryLimit, m.Limit)
+	}
+	return nil
+}
+
+// SizeOf approximates the bytes obj occupies, including the elements of
+// arrays and hashes. Closures count without the environment they capture.
+func SizeOf(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return INTEGER_SIZE
+	case *object.String:
+		return STRING_SIZE + int64(len(obj.Value))
+	case *object.Array:
+		size := int64(ARRAY_SIZE + POINTER_SIZE*cap(obj.Elements))
+		for _, element := range obj.Elements {
+			size += SizeOf(element)
+		}
+		return size
+	case *object.Hash:
+		size := int64(HASH_SIZE)
+		for _, pair := range obj.Pairs {
+			size += PAIR_SIZE + SizeOf(pair.Key) + SizeOf(pair.Value)
+		}
+		return size
+	case *object.Function:
+		return FUNCTION_SIZE + POINTER_SIZE*int64(len(obj.Parameters))
+	case *object.ReturnValue:
+		return POINTER_SIZE + SizeOf(obj.Value)
+	}
+	// booleans and null are shared singletons, builtins are not created
+	// by scripts
+	return 0
+}
+
+// bind accounts binding value to name in scope. Bytes bound in the
+// environment of the current call are released when it returns.
+func (e *Evaluator) bind(scope *object.Environment, name string, value object.Object, old object.Object, rebind bool) *object.Error {
+	if e.Memory == nil {
+		return nil
+	}
+	delta := SizeOf(value) + BINDING_SIZE
+	if rebind {
+		delta -= SizeOf(old) + BINDING_SIZE
+	}
+	if err := e.Memory.reserve(delta); err != nil {
+		return err
+	}
+	if scope == e.frame.env {
+		e.frame.bytes += delta
+	}
+	return nil
+}
+
+// transient accounts a value that exists without being bound, such as the
+// result of a builtin.
+func (e *Evaluator) transient(value object.Object) *object.Error {
+	if e.Memory == nil {
+		return nil
+	}
+	size := SizeOf(value)
+	if err := e.Memory.reserve(size); err != nil {
+		return err
+	}
+	e.Memory.Used -= size
+	return nil
+}
+
+// frame is the environment of the call in progress and the bytes bound in
+// it.
+type frame struct {
+	env   *object.Environment
+	bytes int64
+}
+
+// enter starts accounting for a call with environment env, returning the
+// frame of the caller to pass to leave.
+func (e *Evaluator) enter(env *object.Environment) (frame, *object.Error) {
+	caller := e.frame
+	e.frame = frame{env: env}
+	if e.Memory == nil {
+		return caller, nil
+	}
+	if err := e.Memory.reserve(ENV_SIZE); err != nil {
+		e.frame = caller
+		return caller, err
+	}
+	e.frame.bytes = ENV_SIZE
+	return caller, nil
+}
+
+// leave releases the bytes of the call that returned result, unless result
+// keeps its environment alive.
+func (e *Evaluator) leave(caller frame, result object.Object) {
+	callee := e.frame
+	e.frame = caller
+	if e.Memory == nil {
+		return
+	}
+	if keepsAlive(result, callee.env) {
+		if caller.env != nil {
+			e.frame.bytes += callee.bytes
+		}
+		return
+	}
+	e.Memory.Used -= callee.bytes
+}
+
+// keepsAlive reports whether result is a closure over env, or a tail call
+// to or with one.
+func keepsAlive(result object.Object, env *object.Environment) bool {
+	switch result := result.(type) {
+	case *object.Function:
+		for scope := result.Env; scope != nil; scope = scope.Outer() {
+			if scope == env {
+				return true
+			}
+		}
+	case *tailCall:
+		if keepsAlive(result.fn, env) {
+			return true
+		}
+		for _, arg := range result.args {
+			if keepsAlive(arg, env) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"strings"
+	"testing"
+)
+
+func TestSizeOf(t *testing.T) {
+	tests := []struct {
+		obj      object.Object
+		expected int64
+	}{
+		{&object.Integer{Value: 1}, INTEGER_SIZE},
+		{TRUE, 0},
+		{NULL, 0},
+		{&object.String{Value: "hello"}, STRING_SIZE + 5},
+		{&object.Array{Elements: []object.Object{&object.Integer{}, TRUE}}, ARRAY_SIZE + 2*POINTER_SIZE + INTEGER_SIZE},
+	}
+	for _, test := range tests {
+		if size := SizeOf(test.obj); size != test.expected {
+			t.Errorf("SizeOf(%s) = %d, want %d", test.obj.Inspect(), size, test.expected)
+		}
+	}
+}
+
+func evalWithMemory(input string, memory *Memory, env *object.Environment) object.Object {
+	e := New(context.Background())
+	e.Memory = memory
+	return e.Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+}
+
+func TestMemoryAccounting(t *testing.T) {
+	const binding = INTEGER_SIZE + BINDING_SIZE
+	tests := []struct {
+		input string
+		used  int64
+		peak  int64
+	}{
+		{"let a = 1; let b = 2;", 2 * binding, 2 * binding},
+		{"let a = 1; a = 2; let a = 3;", binding, binding},
+		// i + 1 is made before it is bound
+		{"let i = 0; for (i < 100) { let x = i; i = i + 1; }", 2 * binding, 2*binding + INTEGER_SIZE},
+		// the call's frame, its parameter and local are released on return
+		{"let f = fn(n) { let a = n; a }; let r = f(1);", (FUNCTION_SIZE + POINTER_SIZE + BINDING_SIZE) + binding, (FUNCTION_SIZE + POINTER_SIZE + BINDING_SIZE) + ENV_SIZE + 2*binding},
+		// tail calls release the caller's frame before the callee's is made
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; let r = f(1000);", (FUNCTION_SIZE + POINTER_SIZE + BINDING_SIZE) + binding, (FUNCTION_SIZE + POINTER_SIZE + BINDING_SIZE) + ENV_SIZE + binding + INTEGER_SIZE},
+		// a returned closure keeps the frame it captured
+		{"let adder = fn(x) { fn(y) { x + y } }; let add = adder(1);", 2*(FUNCTION_SIZE+POINTER_SIZE+BINDING_SIZE) + ENV_SIZE + binding, 2*(FUNCTION_SIZE+POINTER_SIZE+BINDING_SIZE) + ENV_SIZE + binding},
+	}
+	for _, test := range tests {
+		memory := &Memory{}
+		evaluated := evalWithMemory(test.input, memory, object.NewEnvironment())
+		if isError(evaluated) {
+			t.Errorf("%s: unexpected error %s", test.input, evaluated.Inspect())
+			continue
+		}
+		if memory.Used != test.used || memory.Peak != test.peak {
+			t.Errorf("%s: used %d peak %d, want used %d peak %d", test.input, memory.Used, memory.Peak, test.used, test.peak)
+		}
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("big", &object.Builtin{Name: "big", Fn: func(args ...object.Object) object.Object {
+		return &object.String{Value: strings.Repeat("x", 1<<20)}
+	}})
+	tests := []string{
+		"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(1000)",
+		"big()",
+		"let adders = fn(n) { let inner = fn(y) { n + y }; if (n == 0) { inner } else { let next = adders(n - 1); next } }; adders(200)",
+		// values made by operators count before they are bound
+		`let s = "` + strings.Repeat("x", 1000) + `"; (s + s + s + s) == ""`,
+	}
+	for _, input := range tests {
+		memory := &Memory{Limit: 4096}
+		evaluated := evalWithMemory(input, memory, object.NewEnclosedEnvironment(env))
+		errorObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected *object.Error got %T (%v)", input, evaluated, evaluated)
+			continue
+		}
+		if !errors.Is(errorObj.Err, ErrMemoryLimit) || errorObj.Message != "memory limit of 4096 bytes exceeded" {
+			t.Errorf("%s: unexpected error %q (%v)", input, errorObj.Message, errorObj.Err)
+		}
+		if memory.Peak > memory.Limit {
+			t.Errorf("%s: peak %d is over the limit", input, memory.Peak)
+		}
+	}
+}
+
+func TestMemoryBind(t *testing.T) {
+	memory := &Memory{Limit: 4096}
+	env := object.NewEnvironment()
+	if err := memory.Bind(env, "s", &object.String{Value: strings.Repeat("x", 1000)}); err != nil {
+		t.Fatal(err)
+	}
+	env.Set("s", &object.String{Value: strings.Repeat("x", 1000)})
+	used := memory.Used
+	if err := memory.Bind(env, "s", &object.String{Value: strings.Repeat("x", 5000)}); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("Bind error = %v, want %v", err, ErrMemoryLimit)
+	}
+	if memory.Used != used {
+		t.Errorf("used %d after a refused binding, want %d", memory.Used, used)
+	}
+}
@@ -0,0 +1,94 @@
+package evaluator
+
+import (
+	"interpreter/ast"
+	"interpreter/object"
+)
+
+// TAIL_CALL_OBJ is the type of tail calls, which never escape the
+// evaluator: the function making one returns it, however deep in an
+// expression its return statement is, and ApplyFunction makes the call.
+const TAIL_CALL_OBJ = "TAIL_CALL"
+
+// tailCall is a call to a function in tail position, returned in place of
+// its result for ApplyFunction to make once the caller has returned.
+type tailCall struct {
+	fn   object.Object
+	args []object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType { return TAIL_CALL_OBJ }
+func (tc *tailCall) Inspect() string         { return "tail call to " + tc.fn.Inspect() }
+
+// evalTail evaluates an expression whose value a function returns as is:
+// the value of a return statement or the last expression of its body,
+// where an if passes the position on to its branches.
+func (e *Evaluator) evalTail(node ast.Expression, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		if err := e.step(); err != nil {
+			return err
+		}
+		function := e.Eval(node.Function, env)
+		if stops(function) {
+			return function
+		}
+		args := e.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && stops(args[0]) {
+			return args[0]
+		}
+		if _, ok := function.(*object.Function); ok {
+			return &tailCall{fn: function, args: args}
+		}
+		return e.ApplyFunction(function, args)
+	case *ast.IfExpression:
+		if err := e.step(); err != nil {
+			return err
+		}
+		condition := e.Eval(node.Condition, env)
+		if stops(condition) {
+			return condition
+		}
+		if IsTruthy(condition) {
+			return e.evalTailBlock(node.Consequence, env)
+		} else if node.Alternative != nil {
+			return e.evalTailBlock(node.Alternative, env)
+		}
+		return NULL
+	}
+	return e.Eval(node, env)
+}
+
+// evalTailBlock evaluates a block in tail position, its last expression
+// with evalTail.
+func (e *Evaluator) evalTailBlock(block *ast.BlockStatement, env *object.Environment) object.Object {
+	if err := e.step(); err != nil {
+		return err
+	}
+	n := len(block.Statements)
+	if n == 0 {
+		return nil
+	}
+	result := e.evalStatements(block.Statements[:n-1], env)
+	if result != nil {
+		if rt := result.Type(); rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			return result
+		}
+	}
+	last, ok := block.Statements[n-1].(*ast.ExpressionStatement)
+	if !ok {
+		return e.Eval(block.Statements[n-1], env)
+	}
+	if err := e.step(); err != nil {
+		return err
+	}
+	return e.evalTail(last.Expression, env)
+}
+
+// complete makes the call a top-level return statement left.
+func (e *Evaluator) complete(result object.Object) object.Object {
+	if call, ok := result.(*tailCall); ok {
+		return e.ApplyFunction(call.fn, call.args)
+	}
+	return result
+}
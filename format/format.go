@@ -0,0 +1,160 @@
+// Package format prints Monkey syntax trees as canonical source code.
+package format
+
+import (
+	"bytes"
+	"interpreter/ast"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"strings"
+)
+
+const indent = "\t"
+
+// Source parses src and returns it in canonical form, or the parser
+// errors if it does not parse. A leading `#!` line is kept as it is.
+func Source(src string) (string, []string) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", p.Errors()
+	}
+	if strings.HasPrefix(src, "#!") {
+		shebang, _, _ := strings.Cut(src, "\n")
+		return strings.TrimRight(shebang, " \t\r") + "\n" + Node(program), nil
+	}
+	return Node(program), nil
+}
+
+// Node returns the canonical source for node: one statement per line,
+// blocks indented with tabs, single spaces around infix operators and
+// parentheses only where precedence requires them.
+func Node(node ast.Node) string {
+	pr := &printer{}
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, stmt := range node.Statements {
+			pr.statement(stmt)
+			pr.out.WriteString("\n")
+		}
+	case ast.Statement:
+		pr.statement(node)
+	case ast.Expression:
+		pr.expression(node, parser.LOWEST)
+	}
+	return pr.out.String()
+}
+
+type printer struct {
+	out   bytes.Buffer
+	depth int
+}
+
+func (pr *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		pr.out.WriteString("let " + stmt.Name.Value + " = ")
+		pr.expression(stmt.Value, parser.LOWEST)
+		pr.out.WriteString(";")
+	case *ast.ReturnStatement:
+		pr.out.WriteString("return ")
+		pr.expression(stmt.ReturnValue, parser.LOWEST)
+		pr.out.WriteString(";")
+	case *ast.AssignmentStatement:
+		pr.out.WriteString(stmt.Ident.Value + " = ")
+		pr.expression(stmt.Value, parser.LOWEST)
+		pr.out.WriteString(";")
+	case *ast.ExpressionStatement:
+		pr.expression(stmt.Expression, parser.LOWEST)
+		if _, ok := stmt.Expression.(*ast.IfExpression); !ok {
+			pr.out.WriteString(";")
+		}
+	case *ast.ForStatement:
+		pr.out.WriteString("for (")
+		pr.expression(stmt.Condition, parser.LOWEST)
+		pr.out.WriteString(") ")
+		pr.block(stmt.Block)
+	case *ast.BlockStatement:
+		pr.block(stmt)
+	default:
+		pr.out.WriteString(stmt.String())
+	}
+}
+
+func (pr *printer) block(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 {
+		pr.out.WriteString("{}")
+		return
+	}
+	pr.out.WriteString("{\n")
+	pr.depth++
+	for _, stmt := range block.Statements {
+		pr.out.WriteString(strings.Repeat(indent, pr.depth))
+		pr.statement(stmt)
+		pr.out.WriteString("\n")
+	}
+	pr.depth--
+	pr.out.WriteString(strings.Repeat(indent, pr.depth) + "}")
+}
+
+// expression prints exp as the operand of an operator binding with
+// precedence, parenthesizing it when it binds less tightly. Right operands
+// are passed precedence+1 as infix operators associate to the left.
+func (pr *printer) expression(exp ast.Expression, precedence int) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		pr.out.WriteString(exp.Value)
+	case *ast.IntegerLiteral, *ast.Boolean:
+		pr.out.WriteString(exp.TokenLiteral())
+	case *ast.StringLiteral:
+		pr.out.WriteString(quote(exp.Value))
+	case *ast.PrefixExpression:
+		pr.out.WriteString(exp.Operator)
+		pr.expression(exp.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		own := parser.Precedence(exp.Token.Type)
+		if own < precedence {
+			pr.out.WriteString("(")
+		}
+		pr.expression(exp.Left, own)
+		pr.out.WriteString(" " + exp.Operator + " ")
+		pr.expression(exp.Right, own+1)
+		if own < precedence {
+			pr.out.WriteString(")")
+		}
+	case *ast.IfExpression:
+		pr.out.WriteString("if (")
+		pr.expression(exp.Condition, parser.LOWEST)
+		pr.out.WriteString(") ")
+		pr.block(exp.Consequence)
+		if exp.Alternative != nil {
+			pr.out.WriteString(" else ")
+			pr.block(exp.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		var params []string
+		for _, param := range exp.Parameters {
+			params = append(params, param.Value)
+		}
+		pr.out.WriteString("fn(" + strings.Join(params, ", ") + ") ")
+		pr.block(exp.Body)
+	case *ast.CallExpression:
+		pr.expression(exp.Function, parser.CALL)
+		pr.out.WriteString("(")
+		for i, arg := range exp.Arguments {
+			if i > 0 {
+				pr.out.WriteString(", ")
+			}
+			pr.expression(arg, parser.LOWEST)
+		}
+		pr.out.WriteString(")")
+	default:
+		pr.out.WriteString(exp.String())
+	}
+}
+
+// quote returns s as a string literal, escaping what the lexer unescapes.
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,49 @@
+package format
+
+import (
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a=1+2*3;", "let a = 1 + 2 * 3;\n"},
+		{"(1+2)*3", "(1 + 2) * 3;\n"},
+		{"1-(2-3)", "1 - (2 - 3);\n"},
+		{"(1-2)-3", "1 - 2 - 3;\n"},
+		{"-(a+b)", "-(a + b);\n"},
+		{"!(1<2)==false", "!(1 < 2) == false;\n"},
+		{"a=a+1;return a;", "a = a + 1;\nreturn a;\n"},
+		{"if(a>1){a}else{ b; c }", "if (a > 1) {\n\ta;\n} else {\n\tb;\n\tc;\n}\n"},
+		{"for(i<10){i=i+1;if(i==5){return i;}}", "for (i < 10) {\n\ti = i + 1;\n\tif (i == 5) {\n\t\treturn i;\n\t}\n}\n"},
+		{"let add=fn(x,y){x+y};", "let add = fn(x, y) {\n\tx + y;\n};\n"},
+		{"let f=fn(){};", "let f = fn() {};\n"},
+		{"add(1,2*3,f())", "add(1, 2 * 3, f());\n"},
+		{"fn(x){x}(1)", "fn(x) {\n\tx;\n}(1);\n"},
+		{`let s="a"+"\"b\"\n";`, "let s = \"a\" + \"\\\"b\\\"\\n\";\n"},
+		{"#!/usr/bin/env monkey \nlet a=1;", "#!/usr/bin/env monkey\nlet a = 1;\n"},
+		{"#!/usr/bin/env monkey", "#!/usr/bin/env monkey\n"},
+	}
+	for _, test := range tests {
+		formatted, errors := Source(test.input)
+		if len(errors) != 0 {
+			t.Fatalf("Source(%q) returned errors %v", test.input, errors)
+		}
+		if formatted != test.expected {
+			t.Errorf("Source(%q) expected %q got %q", test.input, test.expected, formatted)
+		}
+		again, _ := Source(formatted)
+		if again != formatted {
+			t.Errorf("Formatting %q is not stable, got %q", formatted, again)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	_, errors := Source("let = 1;")
+	if len(errors) == 0 {
+		t.Errorf("Expected parser errors for invalid input")
+	}
+}
@@ -0,0 +1,160 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"interpreter/object"
+	"reflect"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// RegisterFunc exposes the Go function fn to scripts as the builtin name.
+// Arguments are converted with object.ToGo and results with
+// object.FromGo. fn may take a context.Context first, which receives the
+// context of the running script, and may be variadic. It may return
+// nothing, a value, an error or a value and an error; a non-nil error
+// becomes a Monkey error.
+//
+// Calls fail with a permission error unless every capability in requires
+// has been granted, at least in part. fn can check access to specific
+// resources with Check.
+func (in *Interpreter) RegisterFunc(name string, fn any, requires ...string) error {
+	for _, capability := range requires {
+		if !capabilities[capability] {
+			return fmt.Errorf("register %s: unknown capability %q", name, capability)
+		}
+	}
+	builtin, err := newBuiltin(name, fn, requires, func() context.Context { return in.ctx })
+	if err != nil {
+		return err
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.builtins.Set(name, builtin)
+	in.funcs[name] = registration{fn: fn, builtin: builtin}
+	return nil
+}
+
+// registration is a function registered with RegisterFunc and the builtin
+// made of it.
+type registration struct {
+	fn      any
+	builtin *object.Builtin
+}
+
+// contextual reports whether the builtin depends on the context of the
+// script calling it, for its permissions or as an argument.
+func (r registration) contextual() bool {
+	t := reflect.TypeOf(r.fn)
+	return len(r.builtin.Requires) > 0 || t.NumIn() > 0 && t.In(0) == contextType
+}
+
+// newBuiltin wraps fn as the builtin name, which passes fn the context ctx
+// returns and fails unless its permissions grant every capability in
+// requires.
+func newBuiltin(name string, fn any, requires []string, ctx func() context.Context) (*object.Builtin, error) {
+	wrapped, err := wrapFunc(name, fn, ctx)
+	if err != nil {
+		return nil, err
+	}
+	builtin := &object.Builtin{Name: name, Requires: requires, Fn: wrapped}
+	if len(requires) > 0 {
+		builtin.Fn = func(args ...object.Object) object.Object {
+			for _, capability := range requires {
+				if !permissionsOf(ctx()).has(capability) {
+					err := fmt.Errorf("%w: %s requires %s", ErrPermission, name, capability)
+					return &object.Error{Message: err.Error(), Err: err}
+				}
+			}
+			return wrapped(args...)
+		}
+	}
+	return builtin, nil
+}
+
+func wrapFunc(name string, fn any, ctx func() context.Context) (object.BuiltinFunction, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("register %s: %T is not a function", name, fn)
+	}
+	t := v.Type()
+
+	withContext := t.NumIn() > 0 && t.In(0) == contextType
+	var params []reflect.Type
+	for i := 0; i < t.NumIn(); i++ {
+		if i == 0 && withContext {
+			continue
+		}
+		params = append(params, t.In(i))
+	}
+
+	returnsError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	switch {
+	case t.NumOut() > 2, t.NumOut() == 2 && !returnsError:
+		return nil, fmt.Errorf("register %s: %s must return at most a value and an error", name, t)
+	}
+
+	return func(args ...object.Object) (result object.Object) {
+		fixed := len(params)
+		if t.IsVariadic() {
+			fixed--
+			if len(args) < fixed {
+				return newError("wrong number of arguments: want at least %d, got=%d", fixed, len(args))
+			}
+		} else if len(args) != fixed {
+			return newError("wrong number of arguments: want=%d, got=%d", fixed, len(args))
+		}
+
+		in := make([]reflect.Value, 0, len(args)+1)
+		if withContext {
+			in = append(in, reflect.ValueOf(ctx()))
+		}
+		for i, arg := range args {
+			param := params[min(i, len(params)-1)]
+			if t.IsVariadic() && i >= fixed {
+				param = param.Elem()
+			}
+			value := reflect.New(param)
+			if err := object.ToGo(arg, value.Interface()); err != nil {
+				var convErr *object.ConversionError
+				if errors.As(err, &convErr) {
+					return newError("%s: argument %d: %s", name, i+1, convErr.Message)
+				}
+				return newError("%s: argument %d: %s", name, i+1, err)
+			}
+			in = append(in, value.Elem())
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				result = newError("%s: panic: %v", name, r)
+			}
+		}()
+		out := v.Call(in)
+
+		if returnsError {
+			if err := out[len(out)-1]; !err.IsNil() {
+				err := err.Interface().(error)
+				return &object.Error{Message: err.Error(), Err: err}
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return object.NULL
+		}
+		converted, err := object.FromGo(out[0].Interface())
+		if err != nil {
+			return newError("%s: result: %s", name, err)
+		}
+		return converted
+	}, nil
+}
+
+func newError(format string, a ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,169 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type ctxKey struct{}
+
+func TestRegisterFunc(t *testing.T) {
+	in := New()
+	var sent []string
+	funcs := map[string]any{
+		"add": func(a, b int) int { return a + b },
+		"sendEmail": func(to string, body string) error {
+			if to == "" {
+				return errors.New("sendEmail: no recipient")
+			}
+			sent = append(sent, to+": "+body)
+			return nil
+		},
+		"sum": func(xs ...int) int {
+			total := 0
+			for _, x := range xs {
+				total += x
+			}
+			return total
+		},
+		"join": func(sep string, parts ...string) string { return strings.Join(parts, sep) },
+		"lookup": func(ctx context.Context, key string) (string, error) {
+			value, _ := ctx.Value(ctxKey{}).(string)
+			return key + "=" + value, nil
+		},
+		"pair":  func(a, b int) []int { return []int{a, b} },
+		"noop":  func() {},
+		"boom":  func() int { panic("exploded") },
+		"first": func(xs []int) int { return xs[0] },
+	}
+	for name, fn := range funcs {
+		if err := in.RegisterFunc(name, fn); err != nil {
+			t.Fatalf("RegisterFunc(%q) returned error: %v", name, err)
+		}
+	}
+	in.Set("to", "ann")
+	in.Set("body", "hello")
+	in.Set("empty", "")
+	in.Set("comma", ",")
+	in.Set("key", "user")
+	in.Set("a", "a")
+	in.Set("b", "b")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"add(1, 2)", "3"},
+		{"sendEmail(to, body)", "null"},
+		{"sum()", "0"},
+		{"sum(1, 2, 3)", "6"},
+		{"join(comma, a, b)", `"a,b"`},
+		{"lookup(key)", `"user=tenant"`},
+		{"pair(1, 2)", "[1, 2]"},
+		{"noop()", "null"},
+		{"first(pair(7, 8))", "7"},
+	}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "tenant")
+	for _, tt := range tests {
+		result, err := in.Run(ctx, "test", tt.input)
+		if err != nil {
+			t.Errorf("Run(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("Run(%q) = %s, want %s", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+	if len(sent) != 1 || sent[0] != "ann: hello" {
+		t.Errorf("sent = %q", sent)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"sendEmail(empty, body)", "sendEmail: no recipient"},
+		{"add(1)", "wrong number of arguments: want=2, got=1"},
+		{"join()", "wrong number of arguments: want at least 1, got=0"},
+		{"add(1, true)", "add: argument 2: cannot convert BOOLEAN to int"},
+		{"sum(1, to)", "sum: argument 2: cannot convert STRING to int"},
+		{"boom()", "boom: panic: exploded"},
+	}
+	for _, tt := range errorTests {
+		_, err := in.Run(ctx, "test", tt.input)
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Errorf("Run(%q): expected *RuntimeError, got %v", tt.input, err)
+			continue
+		}
+		if runtimeErr.Message != tt.expected {
+			t.Errorf("Run(%q) error = %q, want %q", tt.input, runtimeErr.Message, tt.expected)
+		}
+	}
+}
+
+func TestRegisterFuncRejects(t *testing.T) {
+	tests := []struct {
+		fn       any
+		expected string
+	}{
+		{42, "register f: int is not a function"},
+		{func() (int, int) { return 0, 0 }, "register f: func() (int, int) must return at most a value and an error"},
+		{func() (int, string, error) { return 0, "", nil }, "register f: func() (int, string, error) must return at most a value and an error"},
+	}
+	for _, tt := range tests {
+		err := New().RegisterFunc("f", tt.fn)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("RegisterFunc(%s) error = %v, want %q", fmt.Sprintf("%T", tt.fn), err, tt.expected)
+		}
+	}
+}
+
+func TestRegisterFuncIsPerInterpreter(t *testing.T) {
+	a, b := New(), New()
+	a.RegisterFunc("one", func() int { return 1 })
+	if _, err := b.Run(context.Background(), "test", "one()"); err == nil {
+		t.Errorf("function registered on one interpreter is visible in another")
+	}
+}
+
+func TestCoreBuiltins(t *testing.T) {
+	in := New()
+	in.Set("xs", []string{"a", "b", "c"})
+	in.Set("empty", []string{})
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"len(xs)", "3"},
+		{`len("four")`, "4"},
+		{"first(xs)", `"a"`},
+		{"first(rest(xs))", `"b"`},
+		{"rest(xs)", `["b", "c"]`},
+		{"first(empty)", "null"},
+		{"rest(empty)", "null"},
+		{"len(1)", "len: unsupported argument INTEGER"},
+		{`first("a")`, "first: unsupported argument STRING"},
+		{"rest(1)", "rest: unsupported argument INTEGER"},
+	}
+	for _, tt := range tests {
+		result, err := in.Run(context.Background(), "test", tt.input)
+		var runtimeErr *RuntimeError
+		switch {
+		case errors.As(err, &runtimeErr):
+			if runtimeErr.Message != tt.expected {
+				t.Errorf("Run(%q) error = %q, want %q", tt.input, runtimeErr.Message, tt.expected)
+			}
+		case err != nil:
+			t.Errorf("Run(%q) returned error: %v", tt.input, err)
+		case result.Inspect() != tt.expected:
+			t.Errorf("Run(%q) = %s, want %s", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+	if xs, _ := in.Get("xs"); xs.Inspect() != `["a", "b", "c"]` {
+		t.Errorf("rest changed its argument: %s", xs.Inspect())
+	}
+}
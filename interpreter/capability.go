@@ -0,0 +1,178 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Capabilities builtins may require. A grant names a capability, or a
+// capability and the resource it is limited to, as in `fs.read:/data` for
+// the files below /data, `net:example.com` for one host or `env:HOME` for
+// one variable.
+const (
+	CAP_FS_READ  = "fs.read"
+	CAP_FS_WRITE = "fs.write"
+	CAP_NET      = "net"
+	CAP_ENV      = "env"
+	CAP_CLOCK    = "clock"
+	CAP_EXEC     = "exec"
+)
+
+var capabilities = map[string]bool{
+	CAP_FS_READ:  true,
+	CAP_FS_WRITE: true,
+	CAP_NET:      true,
+	CAP_ENV:      true,
+	CAP_CLOCK:    true,
+	CAP_EXEC:     true,
+}
+
+// ErrPermission is the cause of errors from builtins called without the
+// capabilities they require.
+var ErrPermission = errors.New("permission denied")
+
+// permissions holds the granted scopes of each capability; an empty scope
+// grants the whole capability.
+type permissions map[string][]string
+
+// Grant gives scripts run by the interpreter the capabilities described by
+// specs, each a capability optionally followed by `:` and a resource.
+// Nothing is granted by default.
+func (in *Interpreter) Grant(specs ...string) error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for _, spec := range specs {
+		capability, scope, scoped := strings.Cut(spec, ":")
+		if !capabilities[capability] {
+			return fmt.Errorf("grant %q: unknown capability %q", spec, capability)
+		}
+		if scoped && scope == "" {
+			return fmt.Errorf("grant %q: empty scope", spec)
+		}
+		if isPathCapability(capability) && scope != "" {
+			path, err := resolvePath(scope)
+			if err != nil {
+				// nothing below a scope that cannot be resolved exists
+				// yet, so its clean path is as good
+				if path, err = filepath.Abs(scope); err != nil {
+					return fmt.Errorf("grant %q: %w", spec, err)
+				}
+			}
+			scope = path
+		}
+		in.perms[capability] = append(in.perms[capability], scope)
+	}
+	return nil
+}
+
+// clone copies p for a run, so that later grants do not affect it.
+func (p permissions) clone() permissions {
+	c := make(permissions, len(p))
+	for capability, scopes := range p {
+		c[capability] = append([]string(nil), scopes...)
+	}
+	return c
+}
+
+// has reports whether any part of capability is granted.
+func (p permissions) has(capability string) bool {
+	return len(p[capability]) > 0
+}
+
+func (p permissions) check(capability, resource string) error {
+	_, err := p.checkPath(capability, resource)
+	return err
+}
+
+// checkPath is check returning the resource to use, for the path
+// capabilities the resolved path that was checked.
+func (p permissions) checkPath(capability, resource string) (string, error) {
+	if isPathCapability(capability) {
+		path, err := resolvePath(resource)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s:%s: %v", ErrPermission, capability, resource, err)
+		}
+		for _, scope := range p[capability] {
+			if scope == "" || within(scope, path) {
+				return path, nil
+			}
+		}
+	} else {
+		for _, scope := range p[capability] {
+			if scope == "" || scope == resource {
+				return resource, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s:%s", ErrPermission, capability, resource)
+}
+
+type permissionsKey struct{}
+
+// Check reports whether the script running under ctx may use resource
+// under capability, returning an error wrapping ErrPermission if not.
+// Functions registered with RegisterFunc call it with the context they
+// receive to guard access to particular files, hosts or variables.
+func Check(ctx context.Context, capability, resource string) error {
+	return permissionsOf(ctx).check(capability, resource)
+}
+
+// CheckPath is Check for the fs capabilities, returning the path with
+// symbolic links resolved that was checked. Functions must open that path
+// rather than the one given, which may lead elsewhere.
+func CheckPath(ctx context.Context, capability, path string) (string, error) {
+	return permissionsOf(ctx).checkPath(capability, path)
+}
+
+func permissionsOf(ctx context.Context) permissions {
+	perms, _ := ctx.Value(permissionsKey{}).(permissions)
+	return perms
+}
+
+func isPathCapability(capability string) bool {
+	return capability == CAP_FS_READ || capability == CAP_FS_WRITE
+}
+
+// resolvePath returns the absolute path of name with symbolic links
+// resolved, so that links cannot lead out of a granted directory. Links
+// are resolved before `..` is applied, as the file system would. For a
+// file that does not exist yet the links of its directory are resolved.
+func resolvePath(name string) (string, error) {
+	path := name
+	if !filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		// not filepath.Join, which would apply `..` before links
+		path = wd + string(filepath.Separator) + path
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved, nil
+	}
+	dir, file := filepath.Split(path)
+	if file == "" || file == "." || file == ".." {
+		return "", fmt.Errorf("cannot resolve %s", name)
+	}
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	path = filepath.Join(dir, file)
+	// a dangling link would be followed to wherever it points
+	if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("cannot resolve %s", name)
+	}
+	return path, nil
+}
+
+// within reports whether path is dir or lies below it.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
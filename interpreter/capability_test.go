@@ -0,0 +1,204 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGrantErrors(t *testing.T) {
+	in := New()
+	if err := in.Grant("fs.delete"); err == nil || err.Error() != `grant "fs.delete": unknown capability "fs.delete"` {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := in.Grant("fs.read:"); err == nil || err.Error() != `grant "fs.read:": empty scope` {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := in.Run(context.Background(), "test", `readFile("/etc/hostname")`); !errors.Is(err, ErrPermission) {
+		t.Errorf("expected permission error after a rejected grant, got %v", err)
+	}
+	if err := in.RegisterFunc("f", func() {}, "teleport"); err == nil || err.Error() != `register f: unknown capability "teleport"` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestFilesystemCapabilities(t *testing.T) {
+	root := t.TempDir()
+	data := filepath.Join(root, "data")
+	os.Mkdir(data, 0755)
+	os.WriteFile(filepath.Join(data, "in.txt"), []byte("inside"), 0644)
+	os.WriteFile(filepath.Join(root, "secret.txt"), []byte("secret"), 0644)
+	os.Symlink(filepath.Join(root, "secret.txt"), filepath.Join(data, "link.txt"))
+	// data/sub/.. is root by the file system but data by the text
+	os.MkdirAll(filepath.Join(root, "outside", "sub"), 0755)
+	os.WriteFile(filepath.Join(root, "outside", "secret.txt"), []byte("secret"), 0644)
+	os.Symlink(filepath.Join(root, "outside", "sub"), filepath.Join(data, "sub"))
+	os.Symlink(filepath.Join(root, "outside", "new.txt"), filepath.Join(data, "dangling.txt"))
+
+	in := New()
+	in.Set("data", data)
+	in.Set("root", root)
+	run := func(src string) (string, error) {
+		result, err := in.Run(context.Background(), "test", src)
+		if err != nil {
+			return "", err
+		}
+		return result.Inspect(), nil
+	}
+
+	if _, err := run(`readFile(data + "/in.txt")`); !errors.Is(err, ErrPermission) {
+		t.Fatalf("expected permission error without grant, got %v", err)
+	} else if !strings.Contains(err.Error(), "permission denied: readFile requires fs.read") {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+
+	if err := in.Grant("fs.read:" + data); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input    string
+		expected string
+		denied   bool
+	}{
+		{`readFile(data + "/in.txt")`, `"inside"`, false},
+		{`readFile(root + "/secret.txt")`, "", true},
+		{`readFile(data + "/../secret.txt")`, "", true},
+		{`readFile(data + "/link.txt")`, "", true},
+		{`readFile(data + "/sub/../secret.txt")`, "", true},
+		{`readFile(data + "/sub/../../data/in.txt")`, `"inside"`, false},
+		{`writeFile(data + "/out.txt", "x")`, "", true},
+	}
+	for _, tt := range tests {
+		result, err := run(tt.input)
+		if tt.denied {
+			if !errors.Is(err, ErrPermission) {
+				t.Errorf("%s: expected permission error, got %v %v", tt.input, result, err)
+			}
+			continue
+		}
+		if err != nil || result != tt.expected {
+			t.Errorf("%s = %s, %v, want %s", tt.input, result, err, tt.expected)
+		}
+	}
+
+	in.Grant("fs.write:" + data)
+	if _, err := run(`writeFile(data + "/out.txt", "written")`); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(data, "out.txt")); string(content) != "written" {
+		t.Errorf("out.txt = %q", content)
+	}
+	for _, src := range []string{
+		`writeFile(data + "/sub/../new.txt", "x")`,
+		`writeFile(data + "/dangling.txt", "x")`,
+	} {
+		if _, err := run(src); !errors.Is(err, ErrPermission) {
+			t.Errorf("%s: expected permission error, got %v", src, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "outside", "new.txt")); err == nil {
+		t.Errorf("a write escaped the granted directory")
+	}
+}
+
+func TestScopedCapabilities(t *testing.T) {
+	t.Setenv("MONKEY_TEST", "banana")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	in := New()
+	in.Set("url", srv.URL)
+	in.Grant("env:MONKEY_TEST", "net:"+host, "clock")
+
+	tests := []struct {
+		input    string
+		expected string
+		denied   bool
+	}{
+		{`getenv("MONKEY_TEST")`, `"banana"`, false},
+		{`getenv("HOME")`, "", true},
+		{`fetch(url)`, `"pong"`, false},
+		{`fetch("http://example.com/")`, "", true},
+		{`now() > 0`, "true", false},
+		{`exec("true")`, "", true},
+	}
+	for _, tt := range tests {
+		result, err := in.Run(context.Background(), "test", tt.input)
+		if tt.denied {
+			if !errors.Is(err, ErrPermission) {
+				t.Errorf("%s: expected permission error, got %v %v", tt.input, result, err)
+			}
+			continue
+		}
+		if err != nil || result.Inspect() != tt.expected {
+			t.Errorf("%s = %v, %v, want %s", tt.input, result, err, tt.expected)
+		}
+	}
+}
+
+func TestFetchRedirectsAndLimits(t *testing.T) {
+	denied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("SECRET"))
+	}))
+	defer denied.Close()
+	granted := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/away":
+			http.Redirect(w, r, denied.URL, http.StatusFound)
+		case "/here":
+			http.Redirect(w, r, "/pong", http.StatusFound)
+		case "/big":
+			w.Write(make([]byte, MAX_FETCH_BYTES+1))
+		default:
+			w.Write([]byte("pong"))
+		}
+	}))
+	defer granted.Close()
+
+	in := New()
+	in.Set("url", granted.URL)
+	in.Grant("net:" + strings.TrimPrefix(granted.URL, "http://"))
+	if result, err := in.Run(context.Background(), "test", `fetch(url + "/here")`); err != nil || result.Inspect() != `"pong"` {
+		t.Errorf("redirect within the granted host = %v, %v", result, err)
+	}
+	if result, err := in.Run(context.Background(), "test", `fetch(url + "/away")`); !errors.Is(err, ErrPermission) {
+		t.Errorf("redirect to a host not granted: expected permission error, got %v %v", result, err)
+	}
+	if _, err := in.Run(context.Background(), "test", `fetch(url + "/big")`); err == nil || !strings.Contains(err.Error(), "response body exceeds") {
+		t.Errorf("expected a body size error, got %v", err)
+	}
+}
+
+func TestRegisterFuncRequires(t *testing.T) {
+	in := New()
+	in.RegisterFunc("hostname", func(ctx context.Context, host string) (string, error) {
+		if err := Check(ctx, CAP_NET, host); err != nil {
+			return "", err
+		}
+		u := url.URL{Scheme: "https", Host: host}
+		return u.String(), nil
+	}, CAP_NET)
+	if _, err := in.Run(context.Background(), "test", `hostname("a.example")`); !errors.Is(err, ErrPermission) {
+		t.Errorf("expected permission error, got %v", err)
+	}
+	in.Grant("net:a.example")
+	result, err := in.Run(context.Background(), "test", `hostname("a.example")`)
+	if err != nil || result.Inspect() != `"https://a.example"` {
+		t.Errorf("hostname = %v, %v", result, err)
+	}
+	if _, err := in.Run(context.Background(), "test", `hostname("b.example")`); !errors.Is(err, ErrPermission) {
+		t.Errorf("expected permission error for an ungranted host, got %v", err)
+	}
+	if err := Check(context.Background(), CAP_CLOCK, ""); !errors.Is(err, ErrPermission) {
+		t.Errorf("Check outside of a run = %v, want a permission error", err)
+	}
+}
@@ -0,0 +1,119 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"interpreter/object"
+	"sync"
+	"testing"
+)
+
+// The tests in this file exercise interpreters from many goroutines; run
+// them with -race to check that no state is shared unsafely.
+
+const fibScript = `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+puts(name);
+fib(n)
+`
+
+func TestIndependentInterpreters(t *testing.T) {
+	const workers = 200
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var stdout bytes.Buffer
+			in := New(WithStdout(&stdout), WithMaxSteps(1_000_000), WithMaxMemory(1<<20))
+			in.RegisterFunc("id", func() int { return i })
+			in.Grant(CAP_CLOCK)
+			in.Set("name", fmt.Sprintf("worker %d", i))
+			in.Set("n", 10+i%5)
+			result, err := in.Run(context.Background(), "fib", fibScript)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if expected := fmt.Sprint(fib(10 + i%5)); result.Inspect() != expected {
+				t.Errorf("worker %d: fib = %s, want %s", i, result.Inspect(), expected)
+			}
+			if expected := fmt.Sprintf("worker %d\n", i); stdout.String() != expected {
+				t.Errorf("worker %d: stdout = %q, want %q", i, stdout.String(), expected)
+			}
+			id, err := in.Run(context.Background(), "id", "now(); id()")
+			if err != nil || id.Inspect() != fmt.Sprint(i) {
+				t.Errorf("worker %d: id() = %v, %v", i, id, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestSharedInterpreter(t *testing.T) {
+	in := New()
+	if _, err := in.Run(context.Background(), "setup", "let counter = 0; let inc = fn(by) { counter = counter + by; counter };"); err != nil {
+		t.Fatal(err)
+	}
+	const workers, rounds = 50, 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				var err error
+				switch j % 3 {
+				case 0:
+					_, err = in.Run(context.Background(), "inc", "inc(1)")
+				case 1:
+					_, err = in.Call("inc", 1)
+				case 2:
+					err = in.Set(fmt.Sprintf("v%d", i), j)
+					in.Get("counter")
+					in.Memory()
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	counter, _ := in.Get("counter")
+	// rounds 0, 1, 3, 4, ... increment: all but every third
+	expected := workers * (rounds - rounds/3)
+	if counter.Inspect() != fmt.Sprint(expected) {
+		t.Errorf("counter = %s, want %d", counter.Inspect(), expected)
+	}
+}
+
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+
+func TestResultsDoNotShareIntegers(t *testing.T) {
+	a, b := New(), New()
+	a.Run(context.Background(), "a", "let a = 7; let f = fn() { 7 };")
+	var handed []*object.Integer
+	value, _ := a.Get("a")
+	handed = append(handed, value.(*object.Integer))
+	result, _ := a.Run(context.Background(), "a", "3 + 4")
+	handed = append(handed, result.(*object.Integer))
+	result, _ = a.Call("f")
+	handed = append(handed, result.(*object.Integer))
+	for _, integer := range handed {
+		integer.Value = 99
+	}
+	if result, err := b.Run(context.Background(), "b", "3 + 4"); err != nil || result.Inspect() != "7" {
+		t.Errorf("3 + 4 = %v, %v after modifying values another interpreter returned", result, err)
+	}
+	if result, _ := a.Run(context.Background(), "a", "a"); result.Inspect() != "7" {
+		t.Errorf("a = %s after modifying a value Get returned", result.Inspect())
+	}
+}
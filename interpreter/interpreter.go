@@ -0,0 +1,360 @@
+// Package interpreter embeds Monkey in Go programs. An Interpreter keeps
+// its global bindings between runs, so a host can Set values, Run scripts
+// and then Get results or Call the functions they defined.
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"interpreter/closure"
+	"interpreter/compiler"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"interpreter/vm"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Interpreter holds the state of one embedded Monkey instance: its scopes,
+// builtins, grants and limits. Independent interpreters share nothing, so
+// any number of them can run in parallel. The methods of one interpreter
+// may be called from several goroutines, in which case runs and calls take
+// turns; functions registered with RegisterFunc must not call back into
+// the interpreter running them.
+type Interpreter struct {
+	mu       sync.Mutex
+	builtins *object.Environment
+	// funcs holds the functions registered with RegisterFunc by name
+	funcs   map[string]registration
+	globals *object.Environment
+	// ctx is the context of the running script, passed to registered
+	// functions that accept one
+	ctx    context.Context
+	stdout io.Writer
+	stderr io.Writer
+
+	maxSteps int
+	maxDepth int
+	timeout  time.Duration
+	memory   *evaluator.Memory
+	perms    permissions
+	backend  Backend
+}
+
+// Option configures an Interpreter created by New.
+type Option func(in *Interpreter)
+
+// WithStdout sets the writer puts prints to, os.Stdout by default.
+func WithStdout(w io.Writer) Option {
+	return func(in *Interpreter) { in.stdout = w }
+}
+
+// WithStderr sets the writer eputs prints to, os.Stderr by default.
+func WithStderr(w io.Writer) Option {
+	return func(in *Interpreter) { in.stderr = w }
+}
+
+// WithMaxSteps limits each Run or Call to evaluating n syntax tree nodes.
+func WithMaxSteps(n int) Option {
+	return func(in *Interpreter) { in.maxSteps = n }
+}
+
+// WithMaxDepth limits nested function calls to n, by default
+// evaluator.DEFAULT_MAX_DEPTH.
+func WithMaxDepth(n int) Option {
+	return func(in *Interpreter) { in.maxDepth = n }
+}
+
+// WithMaxMemory limits the approximate bytes held by the interpreter's
+// bindings and the values scripts make, see evaluator.Memory. Runs and Set
+// calls that would cross the limit fail with an error wrapping
+// evaluator.ErrMemoryLimit.
+func WithMaxMemory(bytes int64) Option {
+	return func(in *Interpreter) { in.memory.Limit = bytes }
+}
+
+// WithTimeout limits each Run or Call to d of wall time.
+func WithTimeout(d time.Duration) Option {
+	return func(in *Interpreter) { in.timeout = d }
+}
+
+// Backend selects how Run and Call execute scripts.
+type Backend int
+
+const (
+	// BACKEND_EVALUATOR walks the syntax tree, see the evaluator package.
+	BACKEND_EVALUATOR Backend = iota
+	// BACKEND_CLOSURES converts each script to Go closures before running
+	// it, see the closure package. It is faster but does not account
+	// memory, so with WithMaxMemory runs and calls fail with an error
+	// wrapping errors.ErrUnsupported.
+	BACKEND_CLOSURES
+)
+
+// WithBackend selects the backend running scripts, BACKEND_EVALUATOR by
+// default.
+func WithBackend(b Backend) Option {
+	return func(in *Interpreter) { in.backend = b }
+}
+
+func New(opts ...Option) *Interpreter {
+	in := &Interpreter{
+		ctx:    context.Background(),
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		memory: &evaluator.Memory{},
+		perms:  permissions{},
+		funcs:  make(map[string]registration),
+	}
+	for _, opt := range opts {
+		opt(in)
+	}
+	in.builtins = object.NewEnvironment()
+	in.builtins.Set("puts", &object.Builtin{Name: "puts", Fn: printer(func() io.Writer { return in.stdout })})
+	in.builtins.Set("eputs", &object.Builtin{Name: "eputs", Fn: printer(func() io.Writer { return in.stderr })})
+	for _, builtin := range core {
+		if err := in.RegisterFunc(builtin.name, builtin.fn); err != nil {
+			panic(err)
+		}
+	}
+	for _, builtin := range stdlib {
+		if err := in.RegisterFunc(builtin.name, builtin.fn, builtin.requires); err != nil {
+			panic(err)
+		}
+	}
+	in.globals = object.NewEnclosedEnvironment(in.builtins)
+	return in
+}
+
+// printer returns a builtin printing each argument on its own line, strings
+// without quotes.
+func printer(out func() io.Writer) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		for _, arg := range args {
+			if s, ok := arg.(*object.String); ok {
+				fmt.Fprintln(out(), s.Value)
+			} else {
+				fmt.Fprintln(out(), arg.Inspect())
+			}
+		}
+		return object.NULL
+	}
+}
+
+// ParseError reports the syntax errors of a script.
+type ParseError struct {
+	Name   string
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Name, strings.Join(e.Errors, "; "))
+}
+
+// RuntimeError reports an error object produced by evaluation. Err is set
+// for errors with a Go cause, such as evaluator.ErrStepLimit, and can be
+// matched with errors.Is.
+type RuntimeError struct {
+	Name    string
+	Message string
+	Err     error
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%s: runtime error: %s", e.Name, e.Message)
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// Run parses and evaluates src in the global scope and returns the value
+// of its last statement. name identifies the script in errors. Evaluation
+// stops with an error wrapping evaluator.ErrCanceled once ctx is done.
+func (in *Interpreter) Run(ctx context.Context, name, src string) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Name: name, Errors: p.Errors()}
+	}
+	if in.backend == BACKEND_CLOSURES {
+		if in.memory.Limit > 0 {
+			return nil, fmt.Errorf("%s: WithMaxMemory on BACKEND_CLOSURES: %w", name, errors.ErrUnsupported)
+		}
+		compiled, err := closure.Compile(program)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		in.mu.Lock()
+		defer in.mu.Unlock()
+		r, cancel := in.runner(ctx)
+		defer cancel()
+		return result(name, r.Run(compiled, in.globals))
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	e, cancel := in.evaluator(ctx)
+	defer cancel()
+	return result(name, e.Eval(program, in.globals))
+}
+
+// RunBytecode runs a program compiled by the compiler package, such as one
+// read from a .mkc file, on the vm package's virtual machine. Globals the
+// program uses are looked up in the global scope when it starts, and the
+// ones it binds are stored there when it ends; the functions it defines
+// can only be called by bytecode, not with Call. WithMaxSteps limits the
+// instructions executed. The VM does not account memory, so an
+// interpreter with WithMaxMemory fails with an error wrapping
+// errors.ErrUnsupported instead of running bytecode.
+func (in *Interpreter) RunBytecode(ctx context.Context, name string, bytecode *compiler.Bytecode) (object.Object, error) {
+	if in.memory.Limit > 0 {
+		return nil, fmt.Errorf("%s: WithMaxMemory on bytecode: %w", name, errors.ErrUnsupported)
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	globals := make([]object.Object, len(bytecode.Globals))
+	for i, global := range bytecode.Globals {
+		globals[i], _ = in.globals.Get(global)
+	}
+	ctx, cancel := in.runContext(ctx)
+	defer cancel()
+	machine := vm.NewWithGlobals(ctx, bytecode, globals)
+	machine.MaxSteps = in.maxSteps
+	machine.MaxDepth = in.maxDepth
+	evaluated := machine.Run()
+	for i, global := range bytecode.Globals {
+		if value, ok := in.globals.Get(global); globals[i] != nil && (!ok || value != globals[i]) {
+			in.globals.Set(global, globals[i])
+		}
+	}
+	return result(name, evaluated)
+}
+
+// evaluator returns an evaluator with the interpreter's limits for a run
+// under ctx, see runContext.
+func (in *Interpreter) evaluator(ctx context.Context) (*evaluator.Evaluator, context.CancelFunc) {
+	ctx, cancel := in.runContext(ctx)
+	e := evaluator.New(ctx)
+	e.MaxSteps = in.maxSteps
+	e.MaxDepth = in.maxDepth
+	e.Memory = in.memory
+	return e, cancel
+}
+
+// runner is evaluator for BACKEND_CLOSURES.
+func (in *Interpreter) runner(ctx context.Context) (*closure.Runner, context.CancelFunc) {
+	ctx, cancel := in.runContext(ctx)
+	r := closure.New(ctx)
+	r.MaxSteps = in.maxSteps
+	r.MaxDepth = in.maxDepth
+	return r, cancel
+}
+
+// runContext derives the context of a run under ctx, which it also makes
+// the context of registered functions until cancel is called.
+func (in *Interpreter) runContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	cancel := context.CancelFunc(func() {})
+	if in.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, in.timeout)
+	}
+	ctx = context.WithValue(ctx, permissionsKey{}, in.perms.clone())
+	in.ctx = ctx
+	return ctx, func() {
+		cancel()
+		in.ctx = context.Background()
+	}
+}
+
+// Set binds name to value in the global scope, converting value to a
+// Monkey object. It fails with an error wrapping evaluator.ErrMemoryLimit
+// if the value does not fit within WithMaxMemory.
+func (in *Interpreter) Set(name string, value any) error {
+	obj, err := object.FromGo(value)
+	if err != nil {
+		return fmt.Errorf("set %s: %w", name, err)
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if err := in.memory.Bind(in.globals, name, obj); err != nil {
+		return fmt.Errorf("set %s: %w", name, err)
+	}
+	in.globals.Set(name, obj)
+	return nil
+}
+
+// Builtins returns the scope holding the interpreter's builtins, which
+// its global scope encloses, for environments of other evaluations that
+// should see them too.
+func (in *Interpreter) Builtins() *object.Environment {
+	return in.builtins
+}
+
+// Memory returns the approximate bytes the interpreter's bindings hold and
+// the most they have held at once.
+func (in *Interpreter) Memory() (used, peak int64) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.memory.Used, in.memory.Peak
+}
+
+// Get returns the value bound to name in the global scope.
+func (in *Interpreter) Get(name string) (object.Object, bool) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	value, ok := in.globals.Get(name)
+	if !ok {
+		return nil, false
+	}
+	return object.Detach(value), true
+}
+
+// Call calls the function bound to fnName with args converted to Monkey
+// objects and returns its result.
+func (in *Interpreter) Call(fnName string, args ...any) (object.Object, error) {
+	return in.CallContext(context.Background(), fnName, args...)
+}
+
+// CallContext is Call stopping once ctx is done.
+func (in *Interpreter) CallContext(ctx context.Context, fnName string, args ...any) (object.Object, error) {
+	if in.backend == BACKEND_CLOSURES && in.memory.Limit > 0 {
+		return nil, fmt.Errorf("call %s: WithMaxMemory on BACKEND_CLOSURES: %w", fnName, errors.ErrUnsupported)
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	fn, ok := in.globals.Get(fnName)
+	if !ok {
+		return nil, fmt.Errorf("call %s: function not found", fnName)
+	}
+	objects := make([]object.Object, len(args))
+	for i, arg := range args {
+		obj, err := object.FromGo(arg)
+		if err != nil {
+			return nil, fmt.Errorf("call %s: argument %d: %w", fnName, i, err)
+		}
+		objects[i] = obj
+	}
+	if in.backend == BACKEND_CLOSURES {
+		r, cancel := in.runner(ctx)
+		defer cancel()
+		return result(fnName, r.ApplyFunction(fn, objects))
+	}
+	e, cancel := in.evaluator(ctx)
+	defer cancel()
+	return result(fnName, e.ApplyFunction(fn, objects))
+}
+
+func result(name string, evaluated object.Object) (object.Object, error) {
+	if err, ok := evaluated.(*object.Error); ok {
+		return nil, &RuntimeError{Name: name, Message: err.Message, Err: err.Err}
+	}
+	if evaluated == nil {
+		return object.NULL, nil
+	}
+	return object.Detach(evaluated), nil
+}
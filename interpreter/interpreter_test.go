@@ -0,0 +1,309 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"interpreter/compiler"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2", "3"},
+		{"let a = 5; a * 2", "10"},
+		{"let f = fn(x) { x + 1 }; f(2)", "3"},
+		{"let a = 1;", "null"},
+	}
+	for _, tt := range tests {
+		result, err := New().Run(context.Background(), "test", tt.input)
+		if err != nil {
+			t.Fatalf("Run(%q) returned error: %v", tt.input, err)
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("Run(%q) = %s, want %s", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestRunKeepsGlobals(t *testing.T) {
+	in := New()
+	if _, err := in.Run(context.Background(), "a", "let counter = 1;"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := in.Run(context.Background(), "b", "counter = counter + 1; counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inspect() != "2" {
+		t.Errorf("counter = %s, want 2", result.Inspect())
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	_, err := New().Run(context.Background(), "bad.mk", "let a 1;")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected *ParseError, got %T (%v)", err, err)
+	}
+	if parseErr.Name != "bad.mk" || len(parseErr.Errors) == 0 {
+		t.Errorf("unexpected parse error %+v", parseErr)
+	}
+
+	_, err = New().Run(context.Background(), "bad.mk", "1 + true")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected *RuntimeError, got %T (%v)", err, err)
+	}
+	if runtimeErr.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("unexpected message %q", runtimeErr.Message)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New().Run(ctx, "test", "1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func compileScript(t *testing.T, src string) *compiler.Bytecode {
+	t.Helper()
+	c := compiler.New()
+	if err := c.Compile(parser.New(lexer.New(src)).ParseProgram()); err != nil {
+		t.Fatal(err)
+	}
+	return c.Bytecode()
+}
+
+func TestRunBytecode(t *testing.T) {
+	var stdout bytes.Buffer
+	in := New(WithStdout(&stdout))
+	in.Set("base", 40)
+	bytecode := compileScript(t, "let add = fn(n) { base + n }; let total = add(2); puts(total); total")
+	result, err := in.RunBytecode(context.Background(), "test.mkc", bytecode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inspect() != "42" || stdout.String() != "42\n" {
+		t.Errorf("result = %s, stdout = %q", result.Inspect(), stdout.String())
+	}
+	if total, ok := in.Get("total"); !ok || total.Inspect() != "42" {
+		t.Errorf("total = %v, want 42 bound in the global scope", total)
+	}
+	if _, err := in.Run(context.Background(), "next", "total + base"); err != nil {
+		t.Errorf("globals from bytecode unusable by Run: %v", err)
+	}
+
+	_, err = in.RunBytecode(context.Background(), "test.mkc", compileScript(t, "1 + true"))
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Name != "test.mkc" || runtimeErr.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := in.RunBytecode(context.Background(), "test.mkc", compileScript(t, `getenv("HOME")`)); !errors.Is(err, ErrPermission) {
+		t.Errorf("expected a permission error, got %v", err)
+	}
+	_, err = New(WithMaxSteps(500)).RunBytecode(context.Background(), "test.mkc", compileScript(t, "for (true) {}"))
+	if !errors.Is(err, evaluator.ErrStepLimit) {
+		t.Errorf("expected the step limit to apply, got %v", err)
+	}
+	_, err = New(WithMaxMemory(1<<20)).RunBytecode(context.Background(), "test.mkc", compileScript(t, "1"))
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected a memory limit to be refused, got %v", err)
+	}
+}
+
+func TestBackendClosures(t *testing.T) {
+	var stdout bytes.Buffer
+	in := New(WithBackend(BACKEND_CLOSURES), WithStdout(&stdout), WithMaxSteps(10000))
+	in.Set("base", 10)
+	if _, err := in.Run(context.Background(), "test", "let add = fn(a, b) { base + a + b }; puts(add(1, 2));"); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "13\n" {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+	result, err := in.Call("add", 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inspect() != "15" {
+		t.Errorf("add(2, 3) = %s, want 15", result.Inspect())
+	}
+	if _, err := in.Run(context.Background(), "test", "for (true) {}"); !errors.Is(err, evaluator.ErrStepLimit) {
+		t.Errorf("Run(for (true) {}) error = %v, want %v", err, evaluator.ErrStepLimit)
+	}
+
+	limited := New(WithBackend(BACKEND_CLOSURES), WithMaxMemory(1<<20))
+	if _, err := limited.Run(context.Background(), "test", "let f = fn() { 1 };"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Run with a memory limit error = %v, want %v", err, errors.ErrUnsupported)
+	}
+	if _, err := limited.Call("f"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Call with a memory limit error = %v, want %v", err, errors.ErrUnsupported)
+	}
+}
+
+func TestSetGet(t *testing.T) {
+	in := New()
+	values := map[string]any{"n": 7, "b": true, "s": "hi", "nothing": nil, "u": uint8(3)}
+	for name, value := range values {
+		if err := in.Set(name, value); err != nil {
+			t.Fatalf("Set(%q) returned error: %v", name, err)
+		}
+	}
+	result, err := in.Run(context.Background(), "test", "if (b) { n + u }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inspect() != "10" {
+		t.Errorf("result = %s, want 10", result.Inspect())
+	}
+	s, ok := in.Get("s")
+	if !ok || s.(*object.String).Value != "hi" {
+		t.Errorf("Get(s) = %v, %v", s, ok)
+	}
+	if _, ok := in.Get("missing"); ok {
+		t.Errorf("Get(missing) found a value")
+	}
+	if err := in.Set("c", make(chan int)); err == nil {
+		t.Errorf("Set with a channel did not fail")
+	}
+	if err := in.Set("big", uint64(1<<63)); err == nil {
+		t.Errorf("Set with an overflowing integer did not fail")
+	}
+	if err := in.Set("z", (*object.Integer)(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := in.Run(context.Background(), "test", "z"); err != nil || result != object.NULL {
+		t.Errorf("z = %v, %v, want a typed nil object bound as null", result, err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	in := New()
+	if _, err := in.Run(context.Background(), "test", "let add = fn(a, b) { a + b };"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := in.Call("add", 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inspect() != "5" {
+		t.Errorf("add(2, 3) = %s, want 5", result.Inspect())
+	}
+	if _, err := in.Call("add", 1); err == nil {
+		t.Errorf("expected an arity error")
+	}
+	if _, err := in.Call("missing"); err == nil {
+		t.Errorf("expected an error calling an undefined function")
+	}
+}
+
+func TestPuts(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	in := New(WithStdout(&stdout), WithStderr(&stderr))
+	in.Set("a", "a")
+	if _, err := in.Run(context.Background(), "test", "puts(1, a); eputs(true)"); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "1\na\n" {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+	if stderr.String() != "true\n" {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
+
+func TestReturnTailCallWithinExpression(t *testing.T) {
+	for _, backend := range []Backend{BACKEND_EVALUATOR, BACKEND_CLOSURES} {
+		var stdout bytes.Buffer
+		in := New(WithBackend(backend), WithStdout(&stdout))
+		result, err := in.Run(context.Background(), "test", "let f = fn(n) { n }; let x = if (true) { return f(3); }; puts(x)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if integer, ok := result.(*object.Integer); !ok || integer.Value != 3 {
+			t.Errorf("backend %d: result = %s, want 3", backend, result.Inspect())
+		}
+		if stdout.Len() != 0 {
+			t.Errorf("backend %d: stdout = %q, want nothing", backend, stdout.String())
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		opts     []Option
+		input    string
+		expected error
+	}{
+		{[]Option{WithTimeout(10 * time.Millisecond)}, "for (true) {}", evaluator.ErrCanceled},
+		{[]Option{WithTimeout(10 * time.Millisecond)}, "for (true) {}", context.DeadlineExceeded},
+		{[]Option{WithMaxSteps(500)}, "for (true) {}", evaluator.ErrStepLimit},
+		{[]Option{WithMaxDepth(20)}, "let f = fn() { 1 + f() }; f()", evaluator.ErrDepthLimit},
+	}
+	for _, tt := range tests {
+		_, err := New(tt.opts...).Run(context.Background(), "test", tt.input)
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("Run(%q) error = %v, want %v", tt.input, err, tt.expected)
+		}
+	}
+
+	in := New(WithMaxSteps(500))
+	if _, err := in.Run(context.Background(), "test", "let spin = fn() { for (true) {} };"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Call("spin"); !errors.Is(err, evaluator.ErrStepLimit) {
+		t.Errorf("Call(spin) error = %v, want %v", err, evaluator.ErrStepLimit)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := New().CallContext(ctx, "missing"); err == nil {
+		t.Errorf("expected an error calling an undefined function")
+	}
+}
+
+func TestMemory(t *testing.T) {
+	in := New(WithMaxMemory(4096))
+	in.Set("name", "monkey")
+	if _, err := in.Run(context.Background(), "test", "let a = 1;"); err != nil {
+		t.Fatal(err)
+	}
+	used, peak := in.Memory()
+	if used == 0 || peak < used {
+		t.Errorf("Memory() = %d, %d", used, peak)
+	}
+
+	_, err := in.Run(context.Background(), "test", "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(1000)")
+	if !errors.Is(err, evaluator.ErrMemoryLimit) {
+		t.Fatalf("expected memory limit error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "memory limit of 4096 bytes exceeded") {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+	_, peak = in.Memory()
+	if peak <= used || peak > 4096 {
+		t.Errorf("peak %d, want between %d and 4096", peak, used)
+	}
+
+	// frames of the failed run are not left accounted
+	result, err := in.Run(context.Background(), "test", "let g = fn(n) { n }; g(a)")
+	if err != nil || result.Inspect() != "1" {
+		t.Errorf("Run after memory error = %v, %v", result, err)
+	}
+
+	if err := in.Set("big", strings.Repeat("x", 5000)); !errors.Is(err, evaluator.ErrMemoryLimit) {
+		t.Errorf("Set error = %v, want %v", err, evaluator.ErrMemoryLimit)
+	}
+	if _, ok := in.Get("big"); ok {
+		t.Errorf("Set bound a value over the memory limit")
+	}
+}
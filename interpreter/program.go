@@ -0,0 +1,197 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"interpreter/ast"
+	"interpreter/compiler"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/optimizer"
+	"interpreter/parser"
+	"interpreter/vm"
+)
+
+// PROGRAM_NAME identifies compiled programs in errors.
+const PROGRAM_NAME = "<program>"
+
+// Program is a script parsed once to be evaluated many times against
+// different variables. It is immutable, so Eval may be called from any
+// number of goroutines at once.
+type Program struct {
+	program  *ast.Program
+	bytecode *compiler.Bytecode
+	// globals holds the builtins in their global slots, the rest nil,
+	// copied for each evaluation on the VM
+	globals []object.Object
+	// builtins holds the builtins the program refers to, the scope
+	// enclosing each evaluation on the evaluator
+	builtins *object.Environment
+	// contextual lists the functions the program refers to that need the
+	// context of each evaluation, so are wrapped anew for it
+	contextual map[string]registration
+	perms      permissions
+	in         *Interpreter
+}
+
+// Compile parses src into a Program, simplified by the optimizer package
+// and compiled to bytecode, in which every identifier is resolved to a
+// global slot or local ahead of time. Each evaluation runs on the vm
+// package's virtual machine with only the variables the program refers to
+// converted into their slots. Programs see the builtins of an interpreter
+// made with New and evaluations are limited only by their context, see
+// Interpreter.Compile for programs with limits.
+func Compile(src string) (*Program, error) {
+	return New().Compile(src)
+}
+
+// Compile is the package's Compile for programs evaluated with the
+// interpreter's builtins and grants as they are now, and within its
+// WithMaxSteps, WithMaxDepth, WithTimeout and WithMaxMemory limits, the
+// steps being instructions. Each evaluation has a memory quota of its own;
+// as the VM does not account memory, programs of an interpreter with
+// WithMaxMemory are evaluated by the evaluator, looking identifiers up by
+// name. The program does not see the interpreter's global scope.
+func (in *Interpreter) Compile(src string) (*Program, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Name: PROGRAM_NAME, Errors: p.Errors()}
+	}
+	program = optimizer.Optimize(program)
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		return nil, fmt.Errorf("%s: %w", PROGRAM_NAME, err)
+	}
+	prog := &Program{
+		program:    program,
+		bytecode:   c.Bytecode(),
+		builtins:   object.NewEnvironment(),
+		contextual: make(map[string]registration),
+		in:         in,
+	}
+	prog.globals = make([]object.Object, len(prog.bytecode.Globals))
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	prog.perms = in.perms.clone()
+	for i, name := range prog.bytecode.Globals {
+		builtin, ok := in.builtins.Get(name)
+		if !ok {
+			continue
+		}
+		if registration, ok := in.funcs[name]; ok && registration.builtin == builtin && registration.contextual() {
+			prog.contextual[name] = registration
+			continue
+		}
+		prog.globals[i] = builtin
+		prog.builtins.Set(name, builtin)
+	}
+	return prog, nil
+}
+
+// Eval evaluates the program in a fresh scope holding vars, converted with
+// object.FromGo, and returns the value of its last statement.
+func (p *Program) Eval(vars map[string]any) (object.Object, error) {
+	return p.EvalContext(context.Background(), vars)
+}
+
+// EvalContext is Eval stopping once ctx is done.
+func (p *Program) EvalContext(ctx context.Context, vars map[string]any) (object.Object, error) {
+	if p.in.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.in.timeout)
+		defer cancel()
+	}
+	if len(p.contextual) > 0 {
+		ctx = context.WithValue(ctx, permissionsKey{}, p.perms)
+	}
+	if p.in.memory.Limit > 0 {
+		return p.evaluate(ctx, vars)
+	}
+	globals := append([]object.Object(nil), p.globals...)
+	for i, name := range p.bytecode.Globals {
+		if value, ok := vars[name]; ok {
+			obj, err := object.FromGo(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: variable %s: %w", PROGRAM_NAME, name, err)
+			}
+			globals[i] = obj
+			continue
+		}
+		if registration, ok := p.contextual[name]; ok {
+			builtin, err := p.bind(ctx, name, registration)
+			if err != nil {
+				return nil, err
+			}
+			globals[i] = builtin
+		}
+	}
+	machine := vm.NewWithGlobals(ctx, p.bytecode, globals)
+	machine.MaxSteps = p.in.maxSteps
+	machine.MaxDepth = p.in.maxDepth
+	return result(PROGRAM_NAME, p.detach(machine.Run()))
+}
+
+// detach returns a copy of obj if it is one of the program's constants,
+// which every evaluation shares.
+func (p *Program) detach(obj object.Object) object.Object {
+	for _, constant := range p.bytecode.Constants {
+		if obj != constant {
+			continue
+		}
+		switch constant := constant.(type) {
+		case *object.Integer:
+			return &object.Integer{Value: constant.Value}
+		case *object.String:
+			return &object.String{Value: constant.Value}
+		}
+	}
+	return obj
+}
+
+// evaluate is EvalContext on the evaluator, for programs with a memory
+// limit.
+func (p *Program) evaluate(ctx context.Context, vars map[string]any) (object.Object, error) {
+	e := evaluator.New(ctx)
+	e.MaxSteps = p.in.maxSteps
+	e.MaxDepth = p.in.maxDepth
+	e.Memory = &evaluator.Memory{Limit: p.in.memory.Limit}
+	builtins := p.builtins
+	if len(p.contextual) > 0 {
+		builtins = object.NewEnclosedEnvironment(p.builtins)
+		for name, registration := range p.contextual {
+			builtin, err := p.bind(ctx, name, registration)
+			if err != nil {
+				return nil, err
+			}
+			builtins.Set(name, builtin)
+		}
+	}
+	env := object.NewEnclosedEnvironment(builtins)
+	for _, name := range p.bytecode.Globals {
+		value, ok := vars[name]
+		if !ok {
+			continue
+		}
+		obj, err := object.FromGo(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: variable %s: %w", PROGRAM_NAME, name, err)
+		}
+		if err := e.Memory.Bind(env, name, obj); err != nil {
+			return nil, fmt.Errorf("%s: variable %s: %w", PROGRAM_NAME, name, err)
+		}
+		env.Set(name, obj)
+	}
+	return result(PROGRAM_NAME, e.Eval(p.program, env))
+}
+
+// bind wraps a registered function for one evaluation under ctx.
+func (p *Program) bind(ctx context.Context, name string, registration registration) (*object.Builtin, error) {
+	builtin, err := newBuiltin(name, registration.fn, registration.builtin.Requires, func() context.Context { return ctx })
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", PROGRAM_NAME, err)
+	}
+	return builtin, nil
+}
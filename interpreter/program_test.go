@@ -0,0 +1,248 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const pricingRule = `
+let base = price * quantity;
+if (vip) { base - base / 10 } else { if (quantity > 10) { base - 5 } else { base } }
+`
+
+func TestProgramEval(t *testing.T) {
+	program, err := Compile(pricingRule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		vars     map[string]any
+		expected string
+	}{
+		{map[string]any{"price": 10, "quantity": 3, "vip": false}, "30"},
+		{map[string]any{"price": 10, "quantity": 3, "vip": true}, "27"},
+		{map[string]any{"price": 2, "quantity": 20, "vip": false, "unused": make(chan int)}, "35"},
+	}
+	for _, tt := range tests {
+		result, err := program.Eval(tt.vars)
+		if err != nil {
+			t.Errorf("Eval(%v) returned error: %v", tt.vars, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("Eval(%v) = %s, want %s", tt.vars, result.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestProgramErrors(t *testing.T) {
+	var parseErr *ParseError
+	if _, err := Compile("let = 1;"); !errors.As(err, &parseErr) {
+		t.Errorf("expected *ParseError, got %v", err)
+	}
+
+	program, err := Compile("price * quantity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runtimeErr *RuntimeError
+	_, err = program.Eval(map[string]any{"price": 1})
+	if !errors.As(err, &runtimeErr) || runtimeErr.Message != "identifier not found: quantity" {
+		t.Errorf("expected identifier not found error, got %v", err)
+	}
+	if _, err := program.Eval(map[string]any{"price": 1.5, "quantity": 1}); err == nil {
+		t.Errorf("expected a conversion error")
+	}
+
+	// Constant folding leaves the error to evaluation.
+	program, err = Compile("n * (60 / (1 - 1))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = program.Eval(map[string]any{"n": 1})
+	if !errors.As(err, &runtimeErr) || runtimeErr.Message != "division by zero" {
+		t.Errorf("expected a division by zero error, got %v", err)
+	}
+}
+
+func TestProgramEvalIsIsolated(t *testing.T) {
+	program, err := Compile("let total = n; total = total + 1; total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				result, err := program.Eval(map[string]any{"n": n})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if expected := fmt.Sprint(n + 1); result.Inspect() != expected {
+					t.Errorf("Eval(n=%d) = %s, want %s", n, result.Inspect(), expected)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestProgramBuiltins(t *testing.T) {
+	for _, limit := range []int64{0, 1 << 20} {
+		var stdout bytes.Buffer
+		in := New(WithStdout(&stdout), WithMaxMemory(limit))
+		if err := in.RegisterFunc("double", func(n int) int { return 2 * n }); err != nil {
+			t.Fatal(err)
+		}
+		if err := in.RegisterFunc("hasDeadline", func(ctx context.Context) bool {
+			_, ok := ctx.Deadline()
+			return ok
+		}); err != nil {
+			t.Fatal(err)
+		}
+		program, err := in.Compile(`puts(len(s)); if (hasDeadline()) { double(n) } else { 0 }`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		result, err := program.EvalContext(ctx, map[string]any{"s": "abc", "n": 21})
+		cancel()
+		if err != nil {
+			t.Fatalf("limit %d: %v", limit, err)
+		}
+		if result.Inspect() != "42" || stdout.String() != "3\n" {
+			t.Errorf("limit %d: result = %s, stdout = %q", limit, result.Inspect(), stdout.String())
+		}
+		var runtimeErr *RuntimeError
+		_, err = program.Eval(map[string]any{"s": "abc", "n": 21, "hasDeadline": true})
+		if !errors.As(err, &runtimeErr) || runtimeErr.Message != "not a function: BOOLEAN" {
+			t.Errorf("limit %d: Eval error = %v, want variables to shadow builtins", limit, err)
+		}
+
+		program, err = in.Compile(`readFile("in.txt")`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := program.Eval(nil); !errors.Is(err, ErrPermission) {
+			t.Errorf("limit %d: Eval error = %v, want %v", limit, err, ErrPermission)
+		}
+	}
+}
+
+func TestProgramResultsAreCopies(t *testing.T) {
+	program, err := Compile(`if (n) { 5000 } else { "s" }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := program.Eval(map[string]any{"n": true})
+	first.(*object.Integer).Value = 1
+	if again, _ := program.Eval(map[string]any{"n": true}); again.Inspect() != "5000" {
+		t.Errorf("Eval = %s after changing an earlier result, want 5000", again.Inspect())
+	}
+}
+
+var benchVars = map[string]any{"price": 12, "quantity": 7, "vip": true}
+
+func BenchmarkProgramEval(b *testing.B) {
+	program, err := Compile(pricingRule)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := program.Eval(benchVars); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgramEvalParallel(b *testing.B) {
+	program, err := Compile(pricingRule)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := program.Eval(benchVars); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkReparse is the baseline: parsing the rule again for every
+// evaluation.
+func BenchmarkReparse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		program := parser.New(lexer.New(pricingRule)).ParseProgram()
+		env := object.NewEnvironment()
+		for name, value := range benchVars {
+			obj, _ := object.FromGo(value)
+			env.Set(name, obj)
+		}
+		if _, err := result("rule", evaluator.Eval(program, env)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestProgramEvalContext(t *testing.T) {
+	program, err := Compile("for (true) {}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := program.EvalContext(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("EvalContext error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestInterpreterCompileLimits(t *testing.T) {
+	tests := []struct {
+		in       *Interpreter
+		src      string
+		expected error
+	}{
+		{New(WithMaxSteps(1000)), "for (true) {}", evaluator.ErrStepLimit},
+		{New(WithMaxDepth(50)), "let f = fn(n) { 1 + f(n + 1) }; f(0)", evaluator.ErrDepthLimit},
+		{New(WithMaxMemory(4096)), `let s = "x"; for (true) { s = s + s; }`, evaluator.ErrMemoryLimit},
+		{New(WithTimeout(10 * time.Millisecond)), "for (true) {}", context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		program, err := tt.in.Compile(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// every evaluation gets the full limits
+		for i := 0; i < 2; i++ {
+			if _, err := program.Eval(nil); !errors.Is(err, tt.expected) {
+				t.Errorf("%s: Eval error = %v, want %v", tt.src, err, tt.expected)
+			}
+		}
+	}
+
+	program, err := New(WithMaxMemory(4096)).Compile("let n = 1; s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := program.Eval(map[string]any{"s": strings.Repeat("x", 5000)}); !errors.Is(err, evaluator.ErrMemoryLimit) {
+		t.Errorf("Eval error = %v, want variables to count against the memory limit", err)
+	}
+}
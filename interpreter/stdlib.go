@@ -0,0 +1,160 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"interpreter/object"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// core lists the builtins of every interpreter that need no capability.
+var core = []struct {
+	name string
+	fn   any
+}{
+	{"len", length},
+	{"first", first},
+	{"rest", rest},
+}
+
+// length returns the number of bytes of a string or elements of an array.
+func length(value object.Object) (int, error) {
+	switch value := value.(type) {
+	case *object.String:
+		return len(value.Value), nil
+	case *object.Array:
+		return len(value.Elements), nil
+	}
+	return 0, fmt.Errorf("len: unsupported argument %s", value.Type())
+}
+
+// first returns the first element of an array, null if it is empty.
+func first(value object.Object) (object.Object, error) {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return nil, fmt.Errorf("first: unsupported argument %s", value.Type())
+	}
+	if len(array.Elements) == 0 {
+		return object.NULL, nil
+	}
+	return array.Elements[0], nil
+}
+
+// rest returns a new array of the elements of an array after the first,
+// null if it is empty.
+func rest(value object.Object) (object.Object, error) {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return nil, fmt.Errorf("rest: unsupported argument %s", value.Type())
+	}
+	if len(array.Elements) == 0 {
+		return object.NULL, nil
+	}
+	elements := make([]object.Object, len(array.Elements)-1)
+	copy(elements, array.Elements[1:])
+	return &object.Array{Elements: elements}, nil
+}
+
+// stdlib lists the I/O builtins of every interpreter with the capability
+// each requires. They fail with a permission error unless it is granted.
+var stdlib = []struct {
+	name     string
+	fn       any
+	requires string
+}{
+	{"readFile", readFile, CAP_FS_READ},
+	{"writeFile", writeFile, CAP_FS_WRITE},
+	{"getenv", getenv, CAP_ENV},
+	{"now", now, CAP_CLOCK},
+	{"exec", execCommand, CAP_EXEC},
+	{"fetch", fetch, CAP_NET},
+}
+
+func readFile(ctx context.Context, path string) (string, error) {
+	path, err := CheckPath(ctx, CAP_FS_READ, path)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(path)
+	return string(content), err
+}
+
+func writeFile(ctx context.Context, path, content string) error {
+	path, err := CheckPath(ctx, CAP_FS_WRITE, path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+func getenv(ctx context.Context, name string) (string, error) {
+	if err := Check(ctx, CAP_ENV, name); err != nil {
+		return "", err
+	}
+	return os.Getenv(name), nil
+}
+
+// now returns the time in milliseconds since the Unix epoch.
+func now() int64 {
+	return time.Now().UnixMilli()
+}
+
+// execCommand runs name with args and returns its standard output.
+func execCommand(ctx context.Context, name string, args ...string) (string, error) {
+	if err := Check(ctx, CAP_EXEC, name); err != nil {
+		return "", err
+	}
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	return string(out), err
+}
+
+// MAX_FETCH_BYTES bounds the response bodies fetch reads.
+const MAX_FETCH_BYTES = 8 << 20
+
+// fetchClient checks the net grant of the running script again for every
+// host a request is redirected to.
+var fetchClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return Check(req.Context(), CAP_NET, req.URL.Host)
+	},
+}
+
+// fetch returns the body of a GET request to rawURL.
+func fetch(ctx context.Context, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if err := Check(ctx, CAP_NET, u.Host); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MAX_FETCH_BYTES+1))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > MAX_FETCH_BYTES {
+		return "", fmt.Errorf("fetch %s: response body exceeds %d bytes", rawURL, MAX_FETCH_BYTES)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch %s: %s", rawURL, resp.Status)
+	}
+	return string(body), nil
+}
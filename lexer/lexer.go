@@ -9,14 +9,27 @@ type Lexer struct {
 	position     int
 	readPosition int
 	ch           byte
+	line         int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
+	l.skipShebang()
 	return l
 }
 
+// skipShebang skips a leading `#!` interpreter line so that scripts can
+// be made executable.
+func (l *Lexer) skipShebang() {
+	if l.ch != '#' || l.peekChar() != '!' {
+		return
+	}
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+}
+
 func (l *Lexer) peekChar() byte {
 	if l.readPosition >= len(l.input) {
 		return 0
@@ -25,6 +38,9 @@ func (l *Lexer) peekChar() byte {
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -50,6 +66,38 @@ func (l *Lexer) readDigit() string {
 	return l.input[position:l.position]
 }
 
+// readString reads a double quoted string, returning its value with the
+// escapes \", \\, \n and \t decoded. It reports false for a string that is
+// not terminated, or contains an unknown escape.
+func (l *Lexer) readString() (string, bool) {
+	var out []byte
+	for {
+		l.readChar()
+		switch l.ch {
+		case '"':
+			return string(out), true
+		case 0:
+			return `"` + string(out), false
+		case '\\':
+			l.readChar()
+			switch l.ch {
+			case '"', '\\':
+				out = append(out, l.ch)
+			case 'n':
+				out = append(out, '\n')
+			case 't':
+				out = append(out, '\t')
+			case 0:
+				return `"` + string(out) + `\`, false
+			default:
+				return `"` + string(out) + `\` + string(l.ch), false
+			}
+		default:
+			out = append(out, l.ch)
+		}
+	}
+}
+
 func (l *Lexer) skipWhiteSpace() {
 	for l.ch == ' ' || l.ch == '\n' || l.ch == '\t' || l.ch == '\r' {
 		l.readChar()
@@ -59,6 +107,7 @@ func (l *Lexer) skipWhiteSpace() {
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 	l.skipWhiteSpace()
+	line := l.line
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -100,15 +149,23 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACKET, l.ch)
 	case '}':
 		tok = newToken(token.RBRACKET, l.ch)
+	case '"':
+		if str, ok := l.readString(); ok {
+			tok = token.Token{Type: token.STRING, Literal: str}
+		} else {
+			tok = token.Token{Type: token.ILLEGAL, Literal: str}
+		}
 
 	default:
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Line = line
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Literal = l.readDigit()
 			tok.Type = token.INT
+			tok.Line = line
 			return tok
 		} else {
 			tok.Literal = ""
@@ -116,6 +173,7 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	}
 	l.readChar()
+	tok.Line = line
 	return tok
 }
 
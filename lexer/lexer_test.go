@@ -114,3 +114,62 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestShebangLine(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Type
+	}{
+		{"#!/usr/bin/env monkey\nlet a = 1;", []token.Type{token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON, token.EOF}},
+		{"#!/usr/bin/env monkey", []token.Type{token.EOF}},
+		{"1 #! 2", []token.Type{token.INT, token.EOF}},
+	}
+	for _, test := range tests {
+		l := New(test.input)
+		for i, expected := range test.expected {
+			tok := l.NextToken()
+			if tok.Type != expected {
+				t.Fatalf("input %q token %d: expected %q got %q", test.input, i, expected, tok.Type)
+			}
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected token.Token
+	}{
+		{`"hello world"`, token.Token{Type: token.STRING, Literal: "hello world", Line: 1}},
+		{`""`, token.Token{Type: token.STRING, Literal: "", Line: 1}},
+		{`"a\"b\\c\nd\te"`, token.Token{Type: token.STRING, Literal: "a\"b\\c\nd\te", Line: 1}},
+		{`"open`, token.Token{Type: token.ILLEGAL, Literal: `"open`, Line: 1}},
+		{`"bad\q"`, token.Token{Type: token.ILLEGAL, Literal: `"bad\q`, Line: 1}},
+		{`"trailing\`, token.Token{Type: token.ILLEGAL, Literal: `"trailing\`, Line: 1}},
+	}
+	for _, test := range tests {
+		tok := New(test.input).NextToken()
+		if tok != test.expected {
+			t.Errorf("input %q: expected %+v got %+v", test.input, test.expected, tok)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	input := "let a = 1;\n\nlet s = \"two\nlines\";\n  s"
+	expected := []struct {
+		literal string
+		line    int
+	}{
+		{"let", 1}, {"a", 1}, {"=", 1}, {"1", 1}, {";", 1},
+		{"let", 3}, {"s", 3}, {"=", 3}, {"two\nlines", 3}, {";", 4},
+		{"s", 5}, {"", 5},
+	}
+	l := New(input)
+	for i, want := range expected {
+		tok := l.NextToken()
+		if tok.Literal != want.literal || tok.Line != want.line {
+			t.Errorf("token %d: got %q on line %d, want %q on line %d", i, tok.Literal, tok.Line, want.literal, want.line)
+		}
+	}
+}
@@ -3,17 +3,87 @@ package main
 import (
 	"fmt"
 	"interpreter/repl"
+	"io"
 	"os"
-	"os/user"
 )
 
+// Exit codes of the monkey command.
+const (
+	EXIT_OK            = 0
+	EXIT_RUNTIME_ERROR = 1
+	EXIT_USAGE         = 2
+	EXIT_PARSE_ERROR   = 3
+	EXIT_IO_ERROR      = 4
+)
+
+const USAGE = `Usage: monkey <command> [arguments]
+       monkey <file> [args...]
+       monkey < file
+
+Commands:
+	run <file> [args...]      run a script, exposing args to it as the args array;
+	                          --allow-read=dir, --allow-write=dir, --allow-net,
+	                          --allow-env, --allow-clock, --allow-exec and
+	                          --allow=capability[:resource] grant access to I/O;
+	                          files made by monkey build run as bytecode
+	eval -e <expr> [args...]  evaluate an expression, accepting the same flags
+	build <file> [-o output]  compile a script to bytecode, file.mkc by default
+	disasm [file]             print the bytecode of a script or .mkc file (or
+	                          stdin) annotated with source lines
+	check [files...]          parse files (or stdin) and report syntax errors
+	fmt [-w] [files...]       print files (or stdin) in canonical format
+	tokens [file]             print the tokens of a file (or stdin)
+	ast [--dot] [file]        print the parse tree of a file (or stdin)
+	repl [--listen addr]      start the interactive terminal (the default), or
+	                          serve sessions on unix:/path or [tcp:]host:port
+
+Exit codes: 0 success, 1 runtime error, 2 usage error, 3 parse error, 4 I/O error.
+`
+
 func main() {
-	usr, err := user.Current()
-	if err != nil {
-		panic(err)
-	}
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
 
-	fmt.Printf("Hello %, this is Monkey Language Terminal\n", usr.Name)
-	fmt.Printf("Feel free to type any command! \n")
-	repl.Start(os.Stdin, os.Stdout)
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		if !repl.IsTerminal(stdin) {
+			return c.script()
+		}
+		return c.repl(nil)
+	}
+	switch args[0] {
+	case "run":
+		return c.run(args[1:])
+	case "eval":
+		return c.eval(args[1:])
+	case "build":
+		return c.build(args[1:])
+	case "disasm":
+		return c.disasm(args[1:])
+	case "check":
+		return c.check(args[1:])
+	case "fmt":
+		return c.fmt(args[1:])
+	case "tokens":
+		return c.tokens(args[1:])
+	case "ast":
+		return c.ast(args[1:])
+	case "repl":
+		return c.repl(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, USAGE)
+		return EXIT_OK
+	}
+	if info, err := os.Stat(args[0]); err == nil && info.Mode().IsRegular() {
+		return c.run(args)
+	}
+	fmt.Fprintf(stderr, "monkey: unknown command %q\n\n%s", args[0], USAGE)
+	return EXIT_USAGE
 }
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCommand(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeScript(t *testing.T, src string) string {
+	path := filepath.Join(t.TempDir(), "script.mk")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunCommand(t *testing.T) {
+	path := writeScript(t, "let a = 6;\nlet b = a * 7;\nb\n")
+	code, stdout, stderr := runCommand("", "run", path)
+	if code != EXIT_OK || stdout != "42\n" || stderr != "" {
+		t.Errorf("Unexpected result code=%d stdout=%q stderr=%q", code, stdout, stderr)
+	}
+}
+
+func TestScriptArgs(t *testing.T) {
+	path := writeScript(t, "args")
+	code, stdout, _ := runCommand("", "run", path, "one", "two")
+	if code != EXIT_OK || stdout != "[\"one\", \"two\"]\n" {
+		t.Errorf("Expected args to be exposed, got code=%d stdout=%q", code, stdout)
+	}
+	code, stdout, _ = runCommand("", "eval", "-e", "args", "x")
+	if code != EXIT_OK || stdout != "[\"x\"]\n" {
+		t.Errorf("Expected eval args to be exposed, got code=%d stdout=%q", code, stdout)
+	}
+	path = writeScript(t, "puts(len(args), first(rest(args)))")
+	code, stdout, _ = runCommand("", "run", path, "one", "two")
+	if code != EXIT_OK || stdout != "2\ntwo\n" {
+		t.Errorf("Expected a script to read its args, got code=%d stdout=%q", code, stdout)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		args     []string
+		stdin    string
+		expected int
+	}{
+		{[]string{"eval", "-e", "1 + 2"}, "", EXIT_OK},
+		{[]string{"eval", "-e", "1 + true"}, "", EXIT_RUNTIME_ERROR},
+		{[]string{"eval", "-e", "let = 1;"}, "", EXIT_PARSE_ERROR},
+		{[]string{"eval"}, "", EXIT_USAGE},
+		{[]string{"unknown"}, "", EXIT_USAGE},
+		{[]string{"run", "missing.mk"}, "", EXIT_IO_ERROR},
+		{[]string{"check"}, "let a = 1;", EXIT_OK},
+		{[]string{"check"}, "let a 1;", EXIT_PARSE_ERROR},
+		{[]string{"fmt"}, "let = ;", EXIT_PARSE_ERROR},
+	}
+	for _, test := range tests {
+		code, _, _ := runCommand(test.stdin, test.args...)
+		if code != test.expected {
+			t.Errorf("monkey %v: expected exit code %d got %d", test.args, test.expected, code)
+		}
+	}
+}
+
+func TestRuntimeErrorOutput(t *testing.T) {
+	_, stdout, stderr := runCommand("", "eval", "-e", "-true")
+	if stdout != "" || stderr != "-e: runtime error: unknown operator: -BOOLEAN\n" {
+		t.Errorf("Unexpected output stdout=%q stderr=%q", stdout, stderr)
+	}
+}
+
+func TestFmtCommand(t *testing.T) {
+	code, stdout, _ := runCommand("let a=1+2;if(a){a}", "fmt")
+	if code != EXIT_OK || stdout != "let a = 1 + 2;\nif (a) {\n\ta;\n}\n" {
+		t.Errorf("Unexpected fmt output code=%d stdout=%q", code, stdout)
+	}
+
+	path := writeScript(t, "let a=1;")
+	if code, _, _ := runCommand("", "fmt", "-w", path); code != EXIT_OK {
+		t.Fatalf("fmt -w failed with code %d", code)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "let a = 1;\n" {
+		t.Errorf("fmt -w wrote %q", content)
+	}
+
+	path = writeScript(t, "#!/usr/bin/env monkey\nlet a=1;")
+	if code, _, _ := runCommand("", "fmt", "-w", path); code != EXIT_OK {
+		t.Fatalf("fmt -w failed with code %d", code)
+	}
+	content, _ = os.ReadFile(path)
+	if string(content) != "#!/usr/bin/env monkey\nlet a = 1;\n" {
+		t.Errorf("fmt -w wrote %q", content)
+	}
+
+	code, _, stderr := runCommand("let = 1;", "fmt")
+	if code != EXIT_PARSE_ERROR || !strings.HasPrefix(stderr, "<stdin>: ") {
+		t.Errorf("Unexpected fmt errors code=%d stderr=%q", code, stderr)
+	}
+}
+
+func TestTokensAndAstCommands(t *testing.T) {
+	_, stdout, _ := runCommand("a + 1", "tokens")
+	if stdout != "IDENT    \"a\"\n+        \"+\"\nINT      \"1\"\n" {
+		t.Errorf("Unexpected tokens output %q", stdout)
+	}
+	_, stdout, _ = runCommand("a", "ast")
+	if !strings.HasPrefix(stdout, "Program\n") {
+		t.Errorf("Unexpected ast output %q", stdout)
+	}
+	_, stdout, _ = runCommand("a", "ast", "--dot")
+	if !strings.HasPrefix(stdout, "digraph AST {") {
+		t.Errorf("Unexpected ast --dot output %q", stdout)
+	}
+}
+
+func TestStdinScript(t *testing.T) {
+	code, stdout, stderr := runCommand("let b = 2;\nif (b > 1) {\n\tb * 21\n}\n")
+	if code != EXIT_OK || stdout != "42\n" || stderr != "" {
+		t.Errorf("Expected piped stdin to run as one program, got code=%d stdout=%q stderr=%q", code, stdout, stderr)
+	}
+	code, _, _ = runCommand("let a = ;")
+	if code != EXIT_PARSE_ERROR {
+		t.Errorf("Expected parse error exit code for piped stdin, got %d", code)
+	}
+}
+
+func TestShebangScript(t *testing.T) {
+	path := writeScript(t, "#!/usr/bin/env monkey\nlet a = 2;\na * 3\n")
+	code, stdout, stderr := runCommand("", path)
+	if code != EXIT_OK || stdout != "6\n" || stderr != "" {
+		t.Errorf("Expected shebang script to run, got code=%d stdout=%q stderr=%q", code, stdout, stderr)
+	}
+}
+
+func TestRunAllowRead(t *testing.T) {
+	data := t.TempDir()
+	file := filepath.Join(data, "greeting.txt")
+	os.WriteFile(file, []byte("hello"), 0644)
+	path := writeScript(t, `readFile("`+file+`")`)
+
+	code, _, stderr := runCommand("", "run", path)
+	if code != EXIT_RUNTIME_ERROR || !strings.Contains(stderr, "permission denied: readFile requires fs.read") {
+		t.Errorf("Expected a permission error, got code=%d stderr=%q", code, stderr)
+	}
+	code, stdout, stderr := runCommand("", "run", "--allow-read="+data, path)
+	if code != EXIT_OK || stdout != "\"hello\"\n" {
+		t.Errorf("Unexpected result code=%d stdout=%q stderr=%q", code, stdout, stderr)
+	}
+	code, _, stderr = runCommand("", "run", "--allow-read="+t.TempDir(), path)
+	if code != EXIT_RUNTIME_ERROR || !strings.Contains(stderr, "permission denied: fs.read:") {
+		t.Errorf("Expected a permission error outside the granted directory, got code=%d stderr=%q", code, stderr)
+	}
+	code, _, stderr = runCommand("", "run", "--allow=teleport", path)
+	if code != EXIT_USAGE || !strings.Contains(stderr, "unknown capability") {
+		t.Errorf("Expected a usage error for an unknown capability, got code=%d stderr=%q", code, stderr)
+	}
+	for _, flag := range []string{"--allow-read=", "--allow=fs.read:"} {
+		code, _, stderr = runCommand("", "run", flag, path)
+		if code != EXIT_USAGE || !strings.Contains(stderr, "empty scope") {
+			t.Errorf("%s: expected a usage error for an empty scope, got code=%d stderr=%q", flag, code, stderr)
+		}
+	}
+}
+
+func TestPuts(t *testing.T) {
+	code, stdout, _ := runCommand("", "eval", "-e", `puts("a", 1)`)
+	if code != EXIT_OK || stdout != "a\n1\n" {
+		t.Errorf("Unexpected result code=%d stdout=%q", code, stdout)
+	}
+}
+
+func TestBuildAndRunBytecode(t *testing.T) {
+	path := writeScript(t, "let double = fn(x) { x * 2 };\nputs(\"built\");\ndouble(21)\n")
+	output := filepath.Join(filepath.Dir(path), "out.mkc")
+	if code, _, stderr := runCommand("", "build", path, "-o", output); code != EXIT_OK {
+		t.Fatalf("build failed with code %d: %s", code, stderr)
+	}
+	code, stdout, stderr := runCommand("", "run", output)
+	if code != EXIT_OK || stdout != "built\n42\n" || stderr != "" {
+		t.Errorf("run out.mkc: code=%d stdout=%q stderr=%q", code, stdout, stderr)
+	}
+	if code, _, _ := runCommand("", "build", path); code != EXIT_OK {
+		t.Fatalf("build without -o failed with code %d", code)
+	}
+	if _, err := os.Stat(strings.TrimSuffix(path, ".mk") + ".mkc"); err != nil {
+		t.Errorf("expected script.mkc next to the script: %v", err)
+	}
+
+	data, _ := os.ReadFile(output)
+	os.WriteFile(output, data[:len(data)-3], 0644)
+	code, _, stderr = runCommand("", "run", output)
+	if code != EXIT_PARSE_ERROR || !strings.Contains(stderr, "invalid bytecode") {
+		t.Errorf("truncated file: code=%d stderr=%q", code, stderr)
+	}
+	if code, _, _ := runCommand("", "build", path, "extra"); code != EXIT_USAGE {
+		t.Errorf("build with extra arguments: code=%d, want %d", code, EXIT_USAGE)
+	}
+}
+
+func TestDisasm(t *testing.T) {
+	path := writeScript(t, "let add = fn(a, b) {\n  a + b\n};\nadd(1, 2)\n")
+	expected := `main:
+; 1: let add = fn(a, b) {
+0000 OpClosure 0
+0003 OpSetGlobal 0
+; 4: add(1, 2)
+0006 OpGetGlobal 0
+0009 OpConstant 1
+0012 OpConstant 2
+0015 OpCall 2
+0017 OpReturnValue
+
+function 0 (a, b), locals a, b:
+; 2: a + b
+0000 OpGetLocal 0
+0002 OpGetLocal 1
+0004 OpAdd
+0005 OpReturnValue
+
+constants:
+1 1
+2 2
+`
+	code, stdout, _ := runCommand("", "disasm", path)
+	if code != EXIT_OK || stdout != expected {
+		t.Errorf("disasm script: code=%d\n%s", code, stdout)
+	}
+	runCommand("", "build", path)
+	code, stdout, _ = runCommand("", "disasm", strings.TrimSuffix(path, ".mk")+".mkc")
+	if code != EXIT_OK || stdout != expected {
+		t.Errorf("disasm bytecode: code=%d\n%s", code, stdout)
+	}
+	os.Remove(path)
+	_, stdout, _ = runCommand("", "disasm", strings.TrimSuffix(path, ".mk")+".mkc")
+	if !strings.Contains(stdout, "; line 4\n") {
+		t.Errorf("expected line numbers without the source, got\n%s", stdout)
+	}
+}
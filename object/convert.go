@@ -0,0 +1,392 @@
+package object
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// TAG is the struct tag naming the hash key a field converts to, as in
+// `monkey:"name"`. Fields tagged `monkey:"-"` are skipped.
+const TAG = "monkey"
+
+// ConversionError reports a value that has no counterpart on the other
+// side. Path locates it inside the converted value, e.g. `.users[2].age`.
+type ConversionError struct {
+	Path    string
+	Message string
+}
+
+func (e *ConversionError) Error() string {
+	if e.Path == "" {
+		return "object: " + e.Message
+	}
+	return fmt.Sprintf("object: %s at %s", e.Message, e.Path)
+}
+
+// FromGo converts a Go value to a Monkey object. Integers, booleans and
+// strings map to their Monkey types, slices and arrays to arrays, maps and
+// structs to hashes and nil to null. Pointers and interfaces are followed;
+// cyclic values fail with a ConversionError. Objects are returned
+// unchanged, except nil pointers to them, which convert to null.
+func FromGo(value any) (Object, error) {
+	switch value := value.(type) {
+	case Object:
+		if v := reflect.ValueOf(value); v.Kind() == reflect.Pointer && v.IsNil() {
+			return NULL, nil
+		}
+		return value, nil
+	case int:
+		return NewInteger(int64(value)), nil
+	case int64:
+		return NewInteger(value), nil
+	case bool:
+		return NativeBool(value), nil
+	case string:
+		return &String{Value: value}, nil
+	}
+	return fromValue(reflect.ValueOf(value), "", visiting{})
+}
+
+// visiting holds the pointers, maps and slices being converted, which
+// reappear below themselves only in a cyclic value.
+type visiting map[reference]bool
+
+type reference struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+func fromValue(v reflect.Value, path string, seen visiting) (Object, error) {
+	if !v.IsValid() {
+		return NULL, nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			break
+		}
+		ref := reference{v.Type(), v.Pointer()}
+		if seen[ref] {
+			return nil, &ConversionError{path, fmt.Sprintf("cyclic %s", v.Type())}
+		}
+		seen[ref] = true
+		defer delete(seen, ref)
+	}
+	if v.CanInterface() {
+		if obj, ok := v.Interface().(Object); ok {
+			return FromGo(obj)
+		}
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return NativeBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewInteger(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if int64(u) < 0 {
+			return nil, &ConversionError{path, fmt.Sprintf("%d overflows a Monkey integer", u)}
+		}
+		return NewInteger(int64(u)), nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return fromValue(v.Elem(), path, seen)
+	case reflect.Slice:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return &String{Value: string(v.Bytes())}, nil
+		}
+		fallthrough
+	case reflect.Array:
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			element, err := fromValue(v.Index(i), path+"["+strconv.Itoa(i)+"]", seen)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		hash := &Hash{Pairs: make(map[HashKey]HashPair, v.Len())}
+		iter := v.MapRange()
+		for iter.Next() {
+			keyPath := fmt.Sprintf("%s[%v]", path, iter.Key())
+			key, err := fromValue(iter.Key(), keyPath, seen)
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(Hashable)
+			if !ok {
+				return nil, &ConversionError{keyPath, fmt.Sprintf("unusable as hash key: %s", key.Type())}
+			}
+			value, err := fromValue(iter.Value(), keyPath, seen)
+			if err != nil {
+				return nil, err
+			}
+			hash.Pairs[hashable.HashKey()] = HashPair{Key: key, Value: value}
+		}
+		return hash, nil
+	case reflect.Struct:
+		hash := &Hash{Pairs: make(map[HashKey]HashPair)}
+		for _, field := range fields(v.Type()) {
+			fv, err := v.FieldByIndexErr(field.index)
+			if err != nil {
+				// promoted through a nil embedded pointer
+				continue
+			}
+			value, err := fromValue(fv, path+"."+field.name, seen)
+			if err != nil {
+				return nil, err
+			}
+			key := &String{Value: field.name}
+			hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: value}
+		}
+		return hash, nil
+	}
+	return nil, &ConversionError{path, fmt.Sprintf("cannot convert %s to a Monkey value", v.Type())}
+}
+
+// ToGo stores obj in the value target points to, converting it to the
+// target's type. Targets of Object types receive obj itself, or a copy if
+// it is an Integer, which may be shared. Targets of empty interface type
+// receive int64, bool, string, nil, []any and map[string]any (map[any]any
+// for hashes with keys that are not all strings).
+func ToGo(obj Object, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return &ConversionError{"", fmt.Sprintf("ToGo target must be a non-nil pointer, got %T", target)}
+	}
+	return toValue(obj, v.Elem(), "")
+}
+
+func toValue(obj Object, v reflect.Value, path string) error {
+	mismatch := func() error {
+		return &ConversionError{path, fmt.Sprintf("cannot convert %s to %s", obj.Type(), v.Type())}
+	}
+	if obj == nil {
+		obj = NULL
+	}
+	if (v.Kind() != reflect.Interface || v.NumMethod() != 0) && reflect.TypeOf(obj).AssignableTo(v.Type()) {
+		if i, ok := obj.(*Integer); ok {
+			// the host may modify what it receives, not a shared integer
+			obj = &Integer{Value: i.Value}
+		}
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+	if v.Kind() == reflect.Interface {
+		if v.NumMethod() != 0 {
+			return mismatch()
+		}
+		native, err := toNative(obj, path)
+		if err != nil {
+			return err
+		}
+		if native == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(native))
+		}
+		return nil
+	}
+	if obj == NULL {
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map:
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return mismatch()
+	}
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if err := toValue(obj, elem.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	switch obj := obj.(type) {
+	case *Integer:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.OverflowInt(obj.Value) {
+				return &ConversionError{path, fmt.Sprintf("%d overflows %s", obj.Value, v.Type())}
+			}
+			v.SetInt(obj.Value)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if obj.Value < 0 || v.OverflowUint(uint64(obj.Value)) {
+				return &ConversionError{path, fmt.Sprintf("%d overflows %s", obj.Value, v.Type())}
+			}
+			v.SetUint(uint64(obj.Value))
+			return nil
+		}
+	case *Boolean:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(obj.Value)
+			return nil
+		}
+	case *String:
+		if v.Kind() == reflect.String {
+			v.SetString(obj.Value)
+			return nil
+		}
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(obj.Value))
+			return nil
+		}
+	case *Array:
+		switch v.Kind() {
+		case reflect.Slice:
+			slice := reflect.MakeSlice(v.Type(), len(obj.Elements), len(obj.Elements))
+			for i, element := range obj.Elements {
+				if err := toValue(element, slice.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+					return err
+				}
+			}
+			v.Set(slice)
+			return nil
+		case reflect.Array:
+			if v.Len() != len(obj.Elements) {
+				return &ConversionError{path, fmt.Sprintf("cannot convert ARRAY of length %d to %s", len(obj.Elements), v.Type())}
+			}
+			for i, element := range obj.Elements {
+				if err := toValue(element, v.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case *Hash:
+		switch v.Kind() {
+		case reflect.Map:
+			m := reflect.MakeMapWithSize(v.Type(), len(obj.Pairs))
+			for _, pair := range obj.Pairs {
+				keyPath := path + "[" + pair.Key.Inspect() + "]"
+				key := reflect.New(v.Type().Key()).Elem()
+				if err := toValue(pair.Key, key, keyPath); err != nil {
+					return err
+				}
+				value := reflect.New(v.Type().Elem()).Elem()
+				if err := toValue(pair.Value, value, keyPath); err != nil {
+					return err
+				}
+				m.SetMapIndex(key, value)
+			}
+			v.Set(m)
+			return nil
+		case reflect.Struct:
+			for _, field := range fields(v.Type()) {
+				key := &String{Value: field.name}
+				pair, ok := obj.Pairs[key.HashKey()]
+				if !ok {
+					continue
+				}
+				if err := toValue(pair.Value, fieldByIndex(v, field.index), path+"."+field.name); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return mismatch()
+}
+
+// toNative converts obj to the Go value an interface target receives.
+func toNative(obj Object, path string) (any, error) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Null:
+		return nil, nil
+	case *Array:
+		elements := make([]any, len(obj.Elements))
+		for i, element := range obj.Elements {
+			native, err := toNative(element, path+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = native
+		}
+		return elements, nil
+	case *Hash:
+		byString := make(map[string]any, len(obj.Pairs))
+		byAny := make(map[any]any, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			value, err := toNative(pair.Value, path+"["+pair.Key.Inspect()+"]")
+			if err != nil {
+				return nil, err
+			}
+			key, _ := toNative(pair.Key, path)
+			if s, ok := key.(string); ok && byString != nil {
+				byString[s] = value
+			} else {
+				byString = nil
+			}
+			byAny[key] = value
+		}
+		if byString != nil {
+			return byString, nil
+		}
+		return byAny, nil
+	}
+	return nil, &ConversionError{path, fmt.Sprintf("cannot convert %s to a Go value", obj.Type())}
+}
+
+type field struct {
+	name  string
+	index []int
+}
+
+// fields lists the exported fields of a struct type with the hash keys
+// they convert to: the TAG name if set, the field name otherwise.
+func fields(t reflect.Type) []field {
+	var result []field
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup(TAG); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		result = append(result, field{name: name, index: f.Index})
+	}
+	return result
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex allocating the nil embedded
+// pointers on the way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
@@ -0,0 +1,279 @@
+package object
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type address struct {
+	City string `monkey:"city"`
+}
+
+type user struct {
+	Name    string   `monkey:"name"`
+	Age     int      `monkey:"age"`
+	Tags    []string `monkey:"tags"`
+	Home    *address `monkey:"home"`
+	Secret  string   `monkey:"-"`
+	Admin   bool
+	private int
+}
+
+func TestFromGo(t *testing.T) {
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{nil, "null"},
+		{42, "42"},
+		{uint16(7), "7"},
+		{true, "true"},
+		{"hi", `"hi"`},
+		{[]byte("raw"), `"raw"`},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]bool{true, false}, "[true, false]"},
+		{[]any{1, "a", nil}, `[1, "a", null]`},
+		{map[string]int{"b": 2, "a": 1}, `{"a": 1, "b": 2}`},
+		{map[int]bool{1: true}, "{1: true}"},
+		{(*int)(nil), "null"},
+		{[]int(nil), "null"},
+		{&Integer{Value: 5}, "5"},
+		{(*Integer)(nil), "null"},
+		{(*Array)(nil), "null"},
+		{[]Object{(*String)(nil)}, "[null]"},
+		{
+			user{Name: "ann", Age: 30, Tags: []string{"x"}, Home: &address{City: "Tirana"}, Secret: "s", Admin: true},
+			`{"Admin": true, "age": 30, "home": {"city": "Tirana"}, "name": "ann", "tags": ["x"]}`,
+		},
+	}
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Errorf("FromGo(%#v) returned error: %v", tt.input, err)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("FromGo(%#v) = %s, want %s", tt.input, obj.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestFromGoBooleansAreSingletons(t *testing.T) {
+	obj, _ := FromGo(true)
+	if obj != TRUE {
+		t.Errorf("FromGo(true) is not TRUE")
+	}
+	obj, _ = FromGo(false)
+	if obj != FALSE {
+		t.Errorf("FromGo(false) is not FALSE")
+	}
+}
+
+func TestFromGoErrors(t *testing.T) {
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{1.5, "object: cannot convert float64 to a Monkey value"},
+		{uint64(math.MaxUint64), "object: 18446744073709551615 overflows a Monkey integer"},
+		{[]any{1, make(chan int)}, "object: cannot convert chan int to a Monkey value at [1]"},
+		{map[string]any{"f": func() {}}, "object: cannot convert func() to a Monkey value at [f]"},
+		{struct{ Rate float32 }{}, "object: cannot convert float32 to a Monkey value at .Rate"},
+	}
+	for _, tt := range tests {
+		_, err := FromGo(tt.input)
+		var convErr *ConversionError
+		if !errors.As(err, &convErr) {
+			t.Errorf("FromGo(%#v): expected *ConversionError, got %v", tt.input, err)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("FromGo(%#v) error = %q, want %q", tt.input, err.Error(), tt.expected)
+		}
+	}
+}
+
+type node struct {
+	Name string
+	Next *node
+}
+
+func TestFromGoCycles(t *testing.T) {
+	loop := &node{Name: "a"}
+	loop.Next = &node{Name: "b", Next: loop}
+	self := map[string]any{}
+	self["self"] = self
+	list := []any{nil}
+	list[0] = list
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{loop, "object: cyclic *object.node at .Next.Next"},
+		{self, "object: cyclic map[string]interface {} at [self]"},
+		{list, "object: cyclic []interface {} at [0]"},
+	}
+	for _, tt := range tests {
+		_, err := FromGo(tt.input)
+		var convErr *ConversionError
+		if !errors.As(err, &convErr) || err.Error() != tt.expected {
+			t.Errorf("FromGo(%T) error = %v, want %q", tt.input, err, tt.expected)
+		}
+	}
+
+	// A value reached twice without a cycle converts.
+	shared := &node{Name: "shared"}
+	obj, err := FromGo([]*node{shared, shared})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(obj.(*Array).Elements); got != 2 {
+		t.Errorf("got %d elements, want 2", got)
+	}
+}
+
+func TestToGo(t *testing.T) {
+	var i int
+	var u8 uint8
+	var b bool
+	var s string
+	var raw []byte
+	var ints []int
+	var pair [2]string
+	var m map[string]int
+	var p *int
+	var a any
+	var obj Object
+	var usr user
+
+	home := &Hash{Pairs: map[HashKey]HashPair{}}
+	set(home, &String{Value: "city"}, &String{Value: "Tirana"})
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	set(hash, &String{Value: "name"}, &String{Value: "ann"})
+	set(hash, &String{Value: "age"}, &Integer{Value: 30})
+	set(hash, &String{Value: "home"}, home)
+	set(hash, &String{Value: "Admin"}, TRUE)
+	set(hash, &String{Value: "unknown"}, NULL)
+	ages := &Hash{Pairs: map[HashKey]HashPair{}}
+	set(ages, &String{Value: "ann"}, &Integer{Value: 30})
+
+	tests := []struct {
+		obj      Object
+		target   any
+		expected any
+	}{
+		{&Integer{Value: -3}, &i, -3},
+		{&Integer{Value: 200}, &u8, uint8(200)},
+		{TRUE, &b, true},
+		{&String{Value: "hi"}, &s, "hi"},
+		{&String{Value: "raw"}, &raw, []byte("raw")},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}, &ints, []int{1, 2}},
+		{&Array{Elements: []Object{&String{Value: "a"}, &String{Value: "b"}}}, &pair, [2]string{"a", "b"}},
+		{ages, &m, map[string]int{"ann": 30}},
+		{NULL, &m, map[string]int(nil)},
+		{NULL, &p, (*int)(nil)},
+		{&Integer{Value: 9}, &a, int64(9)},
+		{&Array{Elements: []Object{TRUE, NULL}}, &a, []any{true, nil}},
+		{ages, &a, map[string]any{"ann": int64(30)}},
+		{&Integer{Value: 9}, &obj, Object(&Integer{Value: 9})},
+		{hash, &usr, user{Name: "ann", Age: 30, Home: &address{City: "Tirana"}, Admin: true}},
+	}
+	for _, tt := range tests {
+		if err := ToGo(tt.obj, tt.target); err != nil {
+			t.Errorf("ToGo(%s, %T) returned error: %v", tt.obj.Inspect(), tt.target, err)
+			continue
+		}
+		got := reflect.ValueOf(tt.target).Elem().Interface()
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("ToGo(%s, %T) = %#v, want %#v", tt.obj.Inspect(), tt.target, got, tt.expected)
+		}
+	}
+
+	if err := ToGo(&Integer{Value: 1}, &p); err != nil || p == nil || *p != 1 {
+		t.Errorf("ToGo into *int: %v, %v", p, err)
+	}
+}
+
+func TestToGoCopiesIntegers(t *testing.T) {
+	var integer *Integer
+	var obj Object
+	var objects []Object
+	shared := NewInteger(7)
+	for _, target := range []any{&integer, &obj, &objects} {
+		source := Object(shared)
+		if target == &objects {
+			source = &Array{Elements: []Object{shared}}
+		}
+		if err := ToGo(source, target); err != nil {
+			t.Fatalf("ToGo(%T) returned error: %v", target, err)
+		}
+	}
+	for _, got := range []Object{integer, obj, objects[0]} {
+		if got == shared || got.(*Integer).Value != 7 {
+			t.Errorf("ToGo handed out %p holding %s, want a copy of %p", got, got.Inspect(), shared)
+		}
+	}
+	integer.Value = 8
+	if NewInteger(7).Value != 7 {
+		t.Errorf("modifying a converted integer changed the shared one")
+	}
+}
+
+func TestToGoErrors(t *testing.T) {
+	var i int
+	var i8 int8
+	var u uint
+	var s string
+	var pair [2]int
+	var ints []int
+	var usr user
+
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	set(hash, &String{Value: "age"}, &String{Value: "old"})
+
+	tests := []struct {
+		obj      Object
+		target   any
+		expected string
+	}{
+		{&Integer{Value: 1}, i, "object: ToGo target must be a non-nil pointer, got int"},
+		{&String{Value: "a"}, &i, "object: cannot convert STRING to int"},
+		{&Integer{Value: 300}, &i8, "object: 300 overflows int8"},
+		{&Integer{Value: -1}, &u, "object: -1 overflows uint"},
+		{NULL, &s, "object: cannot convert NULL to string"},
+		{&Array{Elements: []Object{&Integer{Value: 1}}}, &pair, "object: cannot convert ARRAY of length 1 to [2]int"},
+		{&Array{Elements: []Object{&Integer{Value: 1}, TRUE}}, &ints, "object: cannot convert BOOLEAN to int at [1]"},
+		{hash, &usr, "object: cannot convert STRING to int at .age"},
+	}
+	for _, tt := range tests {
+		err := ToGo(tt.obj, tt.target)
+		if err == nil {
+			t.Errorf("ToGo(%s, %T) did not fail", tt.obj.Inspect(), tt.target)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("ToGo(%s, %T) error = %q, want %q", tt.obj.Inspect(), tt.target, err.Error(), tt.expected)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := user{Name: "bob", Age: 41, Tags: []string{"a", "b"}, Home: &address{City: "Durres"}}
+	obj, err := FromGo(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out user
+	if err := ToGo(obj, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip = %#v, want %#v", out, in)
+	}
+}
+
+func set(h *Hash, key Object, value Object) {
+	h.Pairs[key.(Hashable).HashKey()] = HashPair{Key: key, Value: value}
+}
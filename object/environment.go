@@ -0,0 +1,77 @@
+package object
+
+import "sort"
+
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object)}
+}
+
+// NewEnclosedEnvironment returns a scope nested in outer, as used for the
+// body of a function call.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+func (e *Environment) Set(name string, value Object) Object {
+	e.store[name] = value
+	return value
+}
+
+// Assign rebinds name in the innermost scope that defines it, reporting
+// false when no scope does.
+func (e *Environment) Assign(name string, value Object) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = value
+			return true
+		}
+	}
+	return false
+}
+
+// Names returns the names bound in this scope in sorted order.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Local returns the value bound to name in this scope, ignoring outer
+// scopes.
+func (e *Environment) Local(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	return obj, ok
+}
+
+// Scope returns the innermost scope that defines name, or nil.
+func (e *Environment) Scope(name string) *Environment {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			return env
+		}
+	}
+	return nil
+}
+
+// Outer returns the scope e is nested in, or nil.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
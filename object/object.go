@@ -1,7 +1,13 @@
 package object
 
 import (
+	"bytes"
 	"fmt"
+	"hash/fnv"
+	"interpreter/ast"
+	"interpreter/code"
+	"sort"
+	"strings"
 )
 
 const (
@@ -10,8 +16,28 @@ const (
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 	ERROR_OBJ        = "ERROR"
+	STRING_OBJ       = "STRING"
+	ARRAY_OBJ        = "ARRAY"
+	FUNCTION_OBJ     = "FUNCTION"
+	BUILTIN_OBJ      = "BUILTIN"
+	HASH_OBJ         = "HASH"
 )
 
+// The boolean and null values are singletons, compared by identity.
+var (
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{}
+	NULL  = &Null{}
+)
+
+// NativeBool returns the TRUE or FALSE singleton for value.
+func NativeBool(value bool) *Boolean {
+	if value {
+		return TRUE
+	}
+	return FALSE
+}
+
 type ObjectType string
 
 type Object interface {
@@ -23,6 +49,81 @@ type Integer struct {
 	Value int64
 }
 
+// Integers from SMALL_INT_MIN to SMALL_INT_MAX are preallocated and
+// shared by NewInteger, so arithmetic on loop counters and the like does
+// not allocate. Integers are never modified, so sharing them is safe;
+// values handed to hosts are copies, see Detach.
+const (
+	SMALL_INT_MIN = -128
+	SMALL_INT_MAX = 1024
+)
+
+var smallInts = func() []Integer {
+	ints := make([]Integer, SMALL_INT_MAX-SMALL_INT_MIN+1)
+	for i := range ints {
+		ints[i].Value = int64(i + SMALL_INT_MIN)
+	}
+	return ints
+}()
+
+// NewInteger returns an Integer holding value, shared if value is small.
+func NewInteger(value int64) *Integer {
+	if value >= SMALL_INT_MIN && value <= SMALL_INT_MAX {
+		return &smallInts[value-SMALL_INT_MIN]
+	}
+	return &Integer{Value: value}
+}
+
+// Detach returns obj with the shared integers it holds, itself or as an
+// element, key or value of its arrays and hashes, replaced by copies, so
+// that the caller may modify what it gets. Arrays and hashes are copied
+// only if they hold a shared integer.
+func Detach(obj Object) Object {
+	switch obj := obj.(type) {
+	case *Integer:
+		if shared(obj) {
+			return &Integer{Value: obj.Value}
+		}
+	case *Array:
+		var elements []Object
+		for i, element := range obj.Elements {
+			detached := Detach(element)
+			if detached != element && elements == nil {
+				elements = append(make([]Object, 0, len(obj.Elements)), obj.Elements[:i]...)
+			}
+			if elements != nil {
+				elements = append(elements, detached)
+			}
+		}
+		if elements != nil {
+			return &Array{Elements: elements}
+		}
+	case *Hash:
+		var pairs map[HashKey]HashPair
+		for key, pair := range obj.Pairs {
+			detached := HashPair{Key: Detach(pair.Key), Value: Detach(pair.Value)}
+			if detached != pair && pairs == nil {
+				pairs = make(map[HashKey]HashPair, len(obj.Pairs))
+				for key, pair := range obj.Pairs {
+					pairs[key] = pair
+				}
+			}
+			if pairs != nil {
+				pairs[key] = detached
+			}
+		}
+		if pairs != nil {
+			return &Hash{Pairs: pairs}
+		}
+	}
+	return obj
+}
+
+// shared reports whether i is one of the integers NewInteger shares.
+func shared(i *Integer) bool {
+	return i.Value >= SMALL_INT_MIN && i.Value <= SMALL_INT_MAX && i == &smallInts[i.Value-SMALL_INT_MIN]
+}
+
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 
@@ -60,7 +161,148 @@ func (r *ReturnValue) Inspect() string {
 
 type Error struct {
 	Message string
+	// Err is the Go error behind Message, if any, for hosts to match with
+	// errors.Is.
+	Err error
 }
 
 func (err Error) Type() ObjectType { return ERROR_OBJ }
 func (err Error) Inspect() string  { return fmt.Sprintf("Error : %q", err.Message) }
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return fmt.Sprintf("%q", s.Value) }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+	var elements []string
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+type Function struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+	var params []string
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(f.Body.String())
+	out.WriteString("\n}")
+	return out.String()
+}
+
+// CompiledFunction is a function lowered to bytecode by the compiler.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	// Locals names the local slots, parameters first.
+	Locals []string
+	// Lines maps the instructions to the source lines they came from.
+	Lines code.LineTable
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string  { return fmt.Sprintf("CompiledFunction[%p]", cf) }
+
+// Scope holds the locals of one call of a compiled function. Closures
+// made during the call keep it as their Env, sharing its slots.
+type Scope struct {
+	Slots []Object
+	Names []string
+	Outer *Scope
+}
+
+// Closure is a compiled function together with the scope it was made in,
+// nil for functions made at the top level.
+type Closure struct {
+	Fn  *CompiledFunction
+	Env *Scope
+}
+
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string  { return fmt.Sprintf("Closure[%p]", c) }
+
+type BuiltinFunction func(args ...Object) Object
+
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+	// Requires lists the capabilities the builtin needs to be granted.
+	Requires []string
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin function " + b.Name }
+
+// HashKey identifies a hashable object, one of Integer, Boolean or String.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+
+// Inspect lists the pairs sorted by key so the output is stable.
+func (h *Hash) Inspect() string {
+	var pairs []string
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
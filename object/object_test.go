@@ -0,0 +1,57 @@
+package object
+
+import "testing"
+
+func TestNewInteger(t *testing.T) {
+	tests := []struct {
+		value  int64
+		shared bool
+	}{
+		{0, true},
+		{SMALL_INT_MIN, true},
+		{SMALL_INT_MAX, true},
+		{SMALL_INT_MIN - 1, false},
+		{SMALL_INT_MAX + 1, false},
+		{-1 << 63, false},
+	}
+	for _, tt := range tests {
+		a, b := NewInteger(tt.value), NewInteger(tt.value)
+		if a.Value != tt.value {
+			t.Errorf("NewInteger(%d).Value = %d", tt.value, a.Value)
+		}
+		if (a == b) != tt.shared {
+			t.Errorf("NewInteger(%d) shared = %t, want %t", tt.value, a == b, tt.shared)
+		}
+	}
+}
+
+func TestDetach(t *testing.T) {
+	shared, own := NewInteger(7), &Integer{Value: 7}
+	if got := Detach(shared); got == shared || got.(*Integer).Value != 7 {
+		t.Errorf("Detach(shared 7) = %p, want a copy of %p", got, shared)
+	}
+	if got := Detach(own); got != own {
+		t.Errorf("Detach copied an integer that is not shared")
+	}
+
+	plain := &Array{Elements: []Object{own, &String{Value: "a"}}}
+	if got := Detach(plain); got != plain {
+		t.Errorf("Detach copied an array without shared integers")
+	}
+	key := &String{Value: "k"}
+	hash := &Hash{Pairs: map[HashKey]HashPair{key.HashKey(): {Key: key, Value: shared}}}
+	array := &Array{Elements: []Object{own, &Array{Elements: []Object{shared}}, hash}}
+	got := Detach(array).(*Array)
+	if got == array || got.Elements[0] != own {
+		t.Fatalf("Detach(%s) = %s", array.Inspect(), got.Inspect())
+	}
+	if inner := got.Elements[1].(*Array).Elements[0]; inner == shared {
+		t.Errorf("nested array still holds the shared integer")
+	}
+	if value := got.Elements[2].(*Hash).Pairs[key.HashKey()].Value; value == shared {
+		t.Errorf("hash still holds the shared integer")
+	}
+	if array.Elements[1].(*Array).Elements[0] != shared {
+		t.Errorf("Detach modified its argument")
+	}
+}
@@ -0,0 +1,176 @@
+// Package optimizer simplifies syntax trees before they are evaluated.
+//
+// It folds prefix and infix expressions whose operands are integer or
+// boolean literals, removes the branches an if with a constant condition
+// cannot take, and drops double negations where only truthiness matters.
+// Operators are applied by the evaluator itself and nothing that would
+// produce an error is folded, so an optimized program yields the same
+// values and errors as the original, only in fewer steps.
+package optimizer
+
+import (
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/object"
+	"interpreter/token"
+	"strconv"
+)
+
+// Optimize rewrites program in place and returns it.
+func Optimize(program *ast.Program) *ast.Program {
+	program.Statements = statements(program.Statements)
+	return program
+}
+
+// statements optimizes a statement list, splicing in the taken branch of
+// an if statement with a constant condition. The value of a list is that
+// of its last statement, which is also the value of the branch.
+func statements(stmts []ast.Statement) []ast.Statement {
+	optimized := make([]ast.Statement, 0, len(stmts))
+	for i, stmt := range stmts {
+		stmt = statement(stmt)
+		if es, ok := stmt.(*ast.ExpressionStatement); ok {
+			if ie, ok := es.Expression.(*ast.IfExpression); ok {
+				if branch, constant := taken(ie); constant {
+					switch {
+					case branch != nil && len(branch.Statements) > 0:
+						optimized = append(optimized, branch.Statements...)
+						continue
+					case i < len(stmts)-1:
+						// An empty branch is NULL, which only matters as the
+						// value of the list.
+						continue
+					}
+				}
+			}
+		}
+		optimized = append(optimized, stmt)
+	}
+	return optimized
+}
+
+func statement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		stmt.Expression = expression(stmt.Expression)
+	case *ast.LetStatement:
+		stmt.Value = expression(stmt.Value)
+	case *ast.AssignmentStatement:
+		stmt.Value = expression(stmt.Value)
+	case *ast.ReturnStatement:
+		stmt.ReturnValue = expression(stmt.ReturnValue)
+	case *ast.ForStatement:
+		stmt.Condition = condition(stmt.Condition)
+		block(stmt.Block)
+	case *ast.BlockStatement:
+		block(stmt)
+	}
+	return stmt
+}
+
+func block(b *ast.BlockStatement) {
+	if b != nil {
+		b.Statements = statements(b.Statements)
+	}
+}
+
+func expression(exp ast.Expression) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		if exp.Operator == "!" {
+			exp.Right = condition(exp.Right)
+		} else {
+			exp.Right = expression(exp.Right)
+		}
+		if right, ok := constant(exp.Right); ok {
+			return fold(exp, evaluator.EvalPrefix(exp.Operator, right))
+		}
+	case *ast.InfixExpression:
+		exp.Left = expression(exp.Left)
+		exp.Right = expression(exp.Right)
+		left, ok := constant(exp.Left)
+		if !ok {
+			break
+		}
+		if right, ok := constant(exp.Right); ok {
+			return fold(exp, evaluator.EvalInfix(left, exp.Operator, right))
+		}
+	case *ast.IfExpression:
+		exp.Condition = condition(exp.Condition)
+		block(exp.Consequence)
+		block(exp.Alternative)
+		// A branch of a single expression can stand for the whole if.
+		if branch, ok := taken(exp); ok && branch != nil && len(branch.Statements) == 1 {
+			if es, ok := branch.Statements[0].(*ast.ExpressionStatement); ok {
+				return es.Expression
+			}
+		}
+	case *ast.FunctionLiteral:
+		block(exp.Body)
+	case *ast.CallExpression:
+		exp.Function = expression(exp.Function)
+		for i, arg := range exp.Arguments {
+			exp.Arguments[i] = expression(arg)
+		}
+	}
+	return exp
+}
+
+// condition optimizes an expression only tested for truthiness, where
+// !!x is the same as x.
+func condition(exp ast.Expression) ast.Expression {
+	exp = expression(exp)
+	for {
+		outer, ok := exp.(*ast.PrefixExpression)
+		if !ok || outer.Operator != "!" {
+			return exp
+		}
+		inner, ok := outer.Right.(*ast.PrefixExpression)
+		if !ok || inner.Operator != "!" {
+			return exp
+		}
+		exp = inner.Right
+	}
+}
+
+// taken returns the branch ie takes if its condition is constant, nil if
+// that is a missing else.
+func taken(ie *ast.IfExpression) (*ast.BlockStatement, bool) {
+	value, ok := constant(ie.Condition)
+	if !ok {
+		return nil, false
+	}
+	if evaluator.IsTruthy(value) {
+		return ie.Consequence, true
+	}
+	return ie.Alternative, true
+}
+
+// constant returns the value of an integer or boolean literal.
+func constant(exp ast.Expression) (object.Object, bool) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return object.NewInteger(exp.Value), true
+	case *ast.Boolean:
+		return object.NativeBool(exp.Value), true
+	}
+	return nil, false
+}
+
+// fold replaces exp with a literal of value, or keeps it if evaluating it
+// fails.
+func fold(exp ast.Expression, value object.Object) ast.Expression {
+	line := ast.Line(exp)
+	switch value := value.(type) {
+	case *object.Integer:
+		literal := strconv.FormatInt(value.Value, 10)
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: literal, Line: line}, Value: value.Value}
+	case *object.Boolean:
+		tok := token.Token{Type: token.FALSE, Literal: "false", Line: line}
+		if value.Value {
+			tok = token.Token{Type: token.TRUE, Literal: "true", Line: line}
+		}
+		return &ast.Boolean{Token: tok, Value: value.Value}
+	}
+	return exp
+}
@@ -0,0 +1,96 @@
+package optimizer
+
+import (
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/evaluator/evaltest"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("%s: parse errors %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(60 * 60 * 24) * days", "(86400 * days)"},
+		{"1 + 2 * 3 - 4 / 2", "5"},
+		{"-(2 + 3)", "-5"},
+		{"x - -1", "(x - -1)"},
+		{"1 < 2 == true", "true"},
+		{"true != false", "true"},
+		{"!true", "false"},
+		{"!5", "false"},
+		{"let a = 2 * 21;", "let a = 42;"},
+		{"fn(x) { x * (2 + 2) }", "fn(x)(x * 4)"},
+		{"f(1 + 1, 2 * x)", "f(2, (2 * x))"},
+		// Errors are left for the evaluator to report.
+		{"10 / 0", "(10 / 0)"},
+		{"x / (1 - 1)", "(x / 0)"},
+		{"1 + true", "(1 + true)"},
+		{"-true", "(-true)"},
+		{"true + false", "(true + false)"},
+		// Constant conditions.
+		{"if (true) { a } else { b }", "a"},
+		{"if (1 > 2) { a } else { b }", "b"},
+		{"let v = if (2 > 1) { a + 1 };", "let v = (a + 1);"},
+		{"if (true) { let a = 1; a } ; b", "let a = 1;ab"},
+		{"if (false) { a }; b", "b"},
+		{"a; if (false) { b }", "aiffalse b"},
+		{"if (x) { a }", "ifx a"},
+		// Double negation only where truthiness is tested.
+		{"if (!!x) { a }", "ifx a"},
+		{"for (!!!x) { a }", "for ( (!x)){\na}"},
+		{"!!x", "(!(!x))"},
+		{"!!(1 < 2)", "true"},
+	}
+	for _, tt := range tests {
+		got := Optimize(parse(t, tt.input)).String()
+		if got != tt.expected {
+			t.Errorf("%s: got %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestOptimizeKeepsSemantics(t *testing.T) {
+	inputs := append([]string{
+		"let days = 3; (60 * 60 * 24) * days",
+		"let f = fn(x) { if (true) { let y = x * 2; return y + 1; } 0 }; f(4)",
+		"let f = fn() { if (true) {} }; f()",
+		"let f = fn() { 1; if (false) { 2 } }; f()",
+		"let x = 0; if (!!x) { 1 } else { 2 }",
+		"let i = 0; for (!!(i < 3)) { i = i + 1; } i",
+		"9223372036854775807 + 1", "-9223372036854775807 - 2",
+	}, evaltest.Corpus...)
+	for _, input := range inputs {
+		want := eval(parse(t, input))
+		got := eval(Optimize(parse(t, input)))
+		if got.Type() != want.Type() || got.Inspect() != want.Inspect() {
+			t.Errorf("%s: optimized = %s, original = %s", input, got.Inspect(), want.Inspect())
+		}
+	}
+}
+
+func eval(program *ast.Program) object.Object {
+	env := object.NewEnvironment()
+	for name, builtin := range evaltest.Builtins {
+		env.Set(name, builtin)
+	}
+	result := evaluator.Eval(program, env)
+	if result == nil {
+		return object.NULL
+	}
+	return result
+}
@@ -34,8 +34,12 @@ var precedences = map[token.Type]int{
 	token.MINUS:    SUM,
 	token.ASTERISK: PRODUCT,
 	token.SLASH:    PRODUCT,
+	token.LPAREN:   CALL,
 }
 
+// Parser holds all the state of one parse, tracing included, so separate
+// parsers can run in parallel; a single Parser is not safe for concurrent
+// use.
 type Parser struct {
 	l *lexer.Lexer
 
@@ -48,10 +52,16 @@ type Parser struct {
 	/// parser fns
 	prefixParseFns map[token.Type]prefixParseFn
 	infixParseFns  map[token.Type]infixParseFn
+
+	/// tracing, nil unless enabled through an Option
+	trc *tracer
 }
 
-func New(l *lexer.Lexer) *Parser {
+func New(l *lexer.Lexer, opts ...Option) *Parser {
 	p := &Parser{l: l, errors: []string{}}
+	for _, opt := range opts {
+		opt(p)
+	}
 
 	p.prefixParseFns = make(map[token.Type]prefixParseFn)
 	p.infixParseFns = make(map[token.Type]infixParseFn)
@@ -64,6 +74,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NEQ, p.ParseInfixExpression)
 	p.registerInfix(token.LT, p.ParseInfixExpression)
 	p.registerInfix(token.GT, p.ParseInfixExpression)
+	p.registerInfix(token.LPAREN, p.ParseCallExpression)
 
 	// identifier
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
@@ -72,6 +83,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.INT, p.ParseIntegerLiteral)
 	p.registerPrefix(token.TRUE, p.ParseBoolean)
 	p.registerPrefix(token.FALSE, p.ParseBoolean)
+	p.registerPrefix(token.STRING, p.ParseStringLiteral)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 
 	// prefix expressions
 	p.registerPrefix(token.BANG, p.ParsePrefixExpression)
@@ -126,6 +139,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 func (p *Parser) ParseStatement() ast.Statement {
+	defer p.untrace(p.trace("ParseStatement"))
 	switch p.currToken.Type {
 	case token.LET:
 		return p.ParseLetStatement()
@@ -143,6 +157,7 @@ func (p *Parser) ParseStatement() ast.Statement {
 
 // ParseLetStatement / statement parser
 func (p *Parser) ParseLetStatement() *ast.LetStatement {
+	defer p.untrace(p.trace("ParseLetStatement"))
 	stm := &ast.LetStatement{Token: p.currToken}
 	if !p.expectPeek(token.IDENT) {
 		return nil
@@ -162,6 +177,7 @@ func (p *Parser) ParseLetStatement() *ast.LetStatement {
 }
 
 func (p *Parser) ParseReturnStatement() ast.Statement {
+	defer p.untrace(p.trace("ParseReturnStatement"))
 	stm := &ast.ReturnStatement{Token: p.currToken}
 	p.NextToken()
 	/// skip expression
@@ -174,7 +190,7 @@ func (p *Parser) ParseReturnStatement() ast.Statement {
 
 // ParseExpressionStatement / expressions
 func (p *Parser) ParseExpressionStatement() *ast.ExpressionStatement {
-	//defer UnTrace(Trace("ParseExpressionStatement"))
+	defer p.untrace(p.trace("ParseExpressionStatement"))
 	stmt := &ast.ExpressionStatement{Token: p.currToken}
 	stmt.Expression = p.ParseExpression(LOWEST)
 	if p.peekTokenAre([]token.Type{token.SEMICOLON, token.EOF}) {
@@ -188,7 +204,7 @@ func (p *Parser) noPrefixParserFnError(t token.Type) {
 }
 
 func (p *Parser) ParseExpression(precedence int) ast.Expression {
-	//defer UnTrace(Trace("ParseExpression"))
+	defer p.untrace(p.trace("ParseExpression"))
 	prefix := p.prefixParseFns[p.currToken.Type]
 	if prefix == nil {
 		p.noPrefixParserFnError(p.currToken.Type)
@@ -210,7 +226,7 @@ func (p *Parser) ParseExpression(precedence int) ast.Expression {
 }
 
 func (p *Parser) ParsePrefixExpression() ast.Expression {
-	//defer UnTrace(Trace("ParsePrefixExpression"))
+	defer p.untrace(p.trace("ParsePrefixExpression"))
 	expression := &ast.PrefixExpression{
 		Token:    p.currToken,
 		Operator: p.currToken.Literal,
@@ -221,7 +237,7 @@ func (p *Parser) ParsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) ParseInfixExpression(left ast.Expression) ast.Expression {
-	//defer UnTrace(Trace("ParseInfixExpression"))
+	defer p.untrace(p.trace("ParseInfixExpression"))
 	expression := &ast.InfixExpression{
 		Token:    p.currToken,
 		Operator: p.currToken.Literal,
@@ -234,7 +250,7 @@ func (p *Parser) ParseInfixExpression(left ast.Expression) ast.Expression {
 }
 
 func (p *Parser) ParseIntegerLiteral() ast.Expression {
-	//defer UnTrace(Trace("ParseIntegerLiteral"))
+	defer p.untrace(p.trace("ParseIntegerLiteral"))
 	literal := &ast.IntegerLiteral{Token: p.currToken}
 
 	value, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
@@ -247,7 +263,20 @@ func (p *Parser) ParseIntegerLiteral() ast.Expression {
 	return literal
 }
 
+func (p *Parser) ParseStringLiteral() ast.Expression {
+	defer p.untrace(p.trace("ParseStringLiteral"))
+	return &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
+}
+
+// parseIllegal reports a token the lexer could not make sense of, such as
+// an unterminated string.
+func (p *Parser) parseIllegal() ast.Expression {
+	p.errors = append(p.errors, fmt.Sprintf("illegal token %q", p.currToken.Literal))
+	return nil
+}
+
 func (p *Parser) ParseBoolean() ast.Expression {
+	defer p.untrace(p.trace("ParseBoolean"))
 	boolean := &ast.Boolean{Token: p.currToken}
 	value, err := strconv.ParseBool(p.currToken.Literal)
 	if nil != err {
@@ -260,6 +289,7 @@ func (p *Parser) ParseBoolean() ast.Expression {
 }
 
 func (p *Parser) ParseGroupedExpression() ast.Expression {
+	defer p.untrace(p.trace("ParseGroupedExpression"))
 	p.NextToken()
 	exp := p.ParseExpression(LOWEST)
 	if !p.expectPeek(token.RPAREN) {
@@ -269,13 +299,14 @@ func (p *Parser) ParseGroupedExpression() ast.Expression {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	//defer UnTrace(Trace("ParseIdentifier"))
+	defer p.untrace(p.trace("ParseIdentifier"))
 	return &ast.Identifier{
 		Token: p.currToken, Value: p.currToken.Literal,
 	}
 }
 
 func (p *Parser) parseIfExpression() ast.Expression {
+	defer p.untrace(p.trace("ParseIfExpression"))
 	exp := &ast.IfExpression{Token: p.currToken}
 	if !p.expectPeek(token.LPAREN) {
 		return nil
@@ -303,6 +334,7 @@ func (p *Parser) parseIfExpression() ast.Expression {
 }
 
 func (p *Parser) ParseBlockStatement() *ast.BlockStatement {
+	defer p.untrace(p.trace("ParseBlockStatement"))
 	block := &ast.BlockStatement{Token: p.currToken, Statements: []ast.Statement{}}
 	p.NextToken()
 	for !p.currentTokenIs(token.EOF) && !p.currentTokenIs(token.RBRACKET) {
@@ -316,6 +348,7 @@ func (p *Parser) ParseBlockStatement() *ast.BlockStatement {
 }
 
 func (p *Parser) ParseFunctionLiteral() ast.Expression {
+	defer p.untrace(p.trace("ParseFunctionLiteral"))
 	lit := &ast.FunctionLiteral{
 		Token: p.currToken,
 	}
@@ -332,6 +365,7 @@ func (p *Parser) ParseFunctionLiteral() ast.Expression {
 }
 
 func (p *Parser) ParseParameters() []*ast.Identifier {
+	defer p.untrace(p.trace("ParseParameters"))
 	var params []*ast.Identifier
 	if p.peekTokenIs(token.RPAREN) {
 		p.NextToken()
@@ -360,7 +394,34 @@ func (p *Parser) ParseParameters() []*ast.Identifier {
 	return params
 }
 
+func (p *Parser) ParseCallExpression(function ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("ParseCallExpression"))
+	exp := &ast.CallExpression{Token: p.currToken, Function: function}
+	exp.Arguments = p.ParseCallArguments()
+	return exp
+}
+
+func (p *Parser) ParseCallArguments() []ast.Expression {
+	args := []ast.Expression{}
+	if p.peekTokenIs(token.RPAREN) {
+		p.NextToken()
+		return args
+	}
+	p.NextToken()
+	args = append(args, p.ParseExpression(LOWEST))
+	for p.peekTokenIs(token.COMMA) {
+		p.NextToken()
+		p.NextToken()
+		args = append(args, p.ParseExpression(LOWEST))
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return args
+}
+
 func (p *Parser) ParseForStatement() *ast.ForStatement {
+	defer p.untrace(p.trace("ParseForStatement"))
 	stmt := &ast.ForStatement{
 		Token: p.currToken,
 	}
@@ -376,10 +437,13 @@ func (p *Parser) ParseForStatement() *ast.ForStatement {
 		return nil
 	}
 	stmt.Block = p.ParseBlockStatement()
-	p.NextToken()
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.NextToken()
+	}
 	return stmt
 }
 func (p *Parser) ParseAssignmentStatement() *ast.AssignmentStatement {
+	defer p.untrace(p.trace("ParseAssignmentStatement"))
 	ident := p.parseIdentifier().(*ast.Identifier)
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -438,6 +502,15 @@ func (p *Parser) peekPrecedence() int {
 	return LOWEST
 }
 
+// Precedence returns the binding power of t as an infix operator, LOWEST
+// for tokens that are not infix operators.
+func Precedence(t token.Type) int {
+	if precedence, ok := precedences[t]; ok {
+		return precedence
+	}
+	return LOWEST
+}
+
 func (p *Parser) currentPrecedence() int {
 	if precedence, ok := precedences[p.currToken.Type]; ok {
 		return precedence
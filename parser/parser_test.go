@@ -128,6 +128,30 @@ func TestBooleanLiteral(t *testing.T) {
 	}
 }
 
+func TestStringLiteral(t *testing.T) {
+	l := lexer.New(`"hello world";`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	assertProgramLength(t, program, 1)
+	stmt := assertExpressionStatement(t, program.Statements[0])
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("Expected *ast.StringLiteral got %T", stmt.Expression)
+	}
+	if literal.Value != "hello world" {
+		t.Errorf("Expected value %q got %q", "hello world", literal.Value)
+	}
+}
+
+func TestIllegalToken(t *testing.T) {
+	p := New(lexer.New(`let a = "open`))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != `illegal token "\"open"` {
+		t.Errorf("Expected an illegal token error, got %q", p.Errors())
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -257,6 +281,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"3 + 4 * 5 == 3 * 1 + 4 * 5;",
 			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
 		},
+		{
+			"a + add(b * c) + d;",
+			"((a + add((b * c))) + d)",
+		},
+		{
+			"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8));",
+			"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))",
+		},
+		{
+			"add(a + b + c * d / f + g);",
+			"add((((a + b) + ((c * d) / f)) + g))",
+		},
 		{
 			"true;", "true",
 		},
@@ -388,6 +424,71 @@ func TestForStatement(t *testing.T) {
 	testInfixExpression(t, assignmentStmt.Value, "i", "+", 1)
 }
 
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	assertProgramLength(t, program, 1)
+	stmt := assertExpressionStatement(t, program.Statements[0])
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("Expected *ast.CallExpression got %T instead", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Function, "add") {
+		return
+	}
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("Expected 3 arguments got %d", len(exp.Arguments))
+	}
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+}
+
+func TestCallArgumentParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"add();", []string{}},
+		{"add(x);", []string{"x"}},
+		{"fn(x, y) { x }(1, true);", []string{"1", "true"}},
+	}
+	for _, test := range tests {
+		p := New(lexer.New(test.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := assertExpressionStatement(t, program.Statements[0])
+		exp, ok := stmt.Expression.(*ast.CallExpression)
+		if !ok {
+			t.Fatalf("Expected *ast.CallExpression got %T instead", stmt.Expression)
+		}
+		if len(exp.Arguments) != len(test.expected) {
+			t.Fatalf("Expected %d arguments got %d", len(test.expected), len(exp.Arguments))
+		}
+		for i, arg := range test.expected {
+			if exp.Arguments[i].String() != arg {
+				t.Errorf("Expected argument %d to be %q got %q", i, arg, exp.Arguments[i].String())
+			}
+		}
+	}
+}
+
+func TestForStatementFollowedByStatement(t *testing.T) {
+	inputs := []string{
+		"for (a) { b; } let c = 1;",
+		"for (a) { b; }; let c = 1;",
+	}
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		assertProgramLength(t, program, 2)
+		testLetStatement(t, program.Statements[1], "c")
+	}
+}
+
 func TestAssignmentStatements(t *testing.T) {
 	tests := []struct {
 		input      string
@@ -630,3 +731,26 @@ func assertExpressionStatement(t *testing.T, statement ast.Statement) *ast.Expre
 	}
 	return stmt
 }
+
+func TestLines(t *testing.T) {
+	input := "let a = 1;\n\nif (a) {\n  a +\n    2\n}\nfn() {}"
+	program := New(lexer.New(input)).ParseProgram()
+	ifExp := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	infix := ifExp.Consequence.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	tests := []struct {
+		node     ast.Node
+		expected int
+	}{
+		{program, 1},
+		{program.Statements[0], 1},
+		{ifExp, 3},
+		{infix, 4},
+		{infix.Right, 5},
+		{program.Statements[2], 7},
+	}
+	for _, tt := range tests {
+		if line := ast.Line(tt.node); line != tt.expected {
+			t.Errorf("%s: line %d, want %d", tt.node, line, tt.expected)
+		}
+	}
+}
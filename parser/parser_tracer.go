@@ -2,30 +2,85 @@ package parser
 
 import (
 	"fmt"
+	"interpreter/token"
+	"io"
 	"strings"
 )
 
-var traceLevel int = 1
-
 const traceIdent = "\t"
 
-func IdentLevel() string {
-	return strings.Repeat(traceIdent, traceLevel)
+// TraceEvent is a single entry of the parser trace log, recorded when a
+// parse function is entered (Begin) or left (End).
+type TraceEvent struct {
+	Function string
+	Token    token.Token
+	Depth    int
+	End      bool
+}
+
+func (e TraceEvent) String() string {
+	kind := "BEGIN"
+	if e.End {
+		kind = "END"
+	}
+	return fmt.Sprintf("%s%s %s (%s %q)",
+		strings.Repeat(traceIdent, e.Depth), kind, e.Function, e.Token.Type, e.Token.Literal)
+}
+
+// Option configures a Parser created by New.
+type Option func(p *Parser)
+
+// WithTrace writes an indented trace of every parse function call to out.
+func WithTrace(out io.Writer) Option {
+	return func(p *Parser) {
+		p.tracer().out = out
+	}
+}
+
+// WithTraceHook passes every trace event to hook, for callers that want a
+// structured log instead of text.
+func WithTraceHook(hook func(TraceEvent)) Option {
+	return func(p *Parser) {
+		p.tracer().hook = hook
+	}
+}
+
+type tracer struct {
+	out   io.Writer
+	hook  func(TraceEvent)
+	depth int
+}
+
+func (p *Parser) tracer() *tracer {
+	if p.trc == nil {
+		p.trc = &tracer{}
+	}
+	return p.trc
 }
 
-func TracePrint(s string) {
-	fmt.Printf("%s%s\n", IdentLevel(), s)
+func (t *tracer) emit(event TraceEvent) {
+	if t.hook != nil {
+		t.hook(event)
+	}
+	if t.out != nil {
+		fmt.Fprintln(t.out, event.String())
+	}
 }
 
-func IncIdent() { traceLevel++ }
-func DecIdent() { traceLevel-- }
-func Trace(msg string) string {
-	IncIdent()
-	TracePrint("Begin " + msg)
-	return msg
+// trace records entering fn; use as `defer p.untrace(p.trace("fn"))`.
+func (p *Parser) trace(fn string) string {
+	if p.trc == nil {
+		return fn
+	}
+	p.trc.depth++
+	p.trc.emit(TraceEvent{Function: fn, Token: p.currToken, Depth: p.trc.depth})
+	return fn
 }
 
-func UnTrace(msg string) {
-	TracePrint("END " + msg)
-	DecIdent()
+func (p *Parser) untrace(fn string) {
+	if p.trc == nil {
+		return
+	}
+	p.trc.emit(TraceEvent{Function: fn, Token: p.currToken, Depth: p.trc.depth, End: true})
+	p.trc.depth--
 }
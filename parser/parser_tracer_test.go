@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"bytes"
+	"interpreter/lexer"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTraceHook(t *testing.T) {
+	var events []TraceEvent
+	p := New(lexer.New("1 + 2;"), WithTraceHook(func(e TraceEvent) {
+		events = append(events, e)
+	}))
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(events) == 0 {
+		t.Fatalf("Expected trace events, got none")
+	}
+	first := events[0]
+	if first.Function != "ParseStatement" || first.Depth != 1 || first.End {
+		t.Errorf("Unexpected first event %+v", first)
+	}
+	last := events[len(events)-1]
+	if last.Function != "ParseStatement" || last.Depth != 1 || !last.End {
+		t.Errorf("Unexpected last event %+v", last)
+	}
+
+	var infix *TraceEvent
+	for i := range events {
+		if events[i].Function == "ParseInfixExpression" {
+			infix = &events[i]
+			break
+		}
+	}
+	if infix == nil {
+		t.Fatalf("ParseInfixExpression was not traced: %v", events)
+	}
+	if infix.Token.Literal != "+" {
+		t.Errorf("Expected ParseInfixExpression to begin on %q, got %q", "+", infix.Token.Literal)
+	}
+}
+
+func TestTraceWriter(t *testing.T) {
+	var out bytes.Buffer
+	p := New(lexer.New("-a;"), WithTrace(&out))
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	trace := out.String()
+	expected := []string{
+		"\tBEGIN ParseStatement (- \"-\")\n",
+		"\n\t\t\t\tBEGIN ParsePrefixExpression (- \"-\")\n",
+		"\n\t\t\t\tEND ParsePrefixExpression (IDENT \"a\")\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(trace, line) {
+			t.Errorf("Trace does not contain %q, got\n%s", line, trace)
+		}
+	}
+}
+
+func TestTraceDisabled(t *testing.T) {
+	p := New(lexer.New("1 + 2;"))
+	p.ParseProgram()
+	if p.trc != nil {
+		t.Errorf("Expected no tracer without trace options")
+	}
+}
+
+func TestTraceConcurrentParsers(t *testing.T) {
+	inputs := []string{"1 + 2 * 3;", "let a = -b;", "if (a) { b } else { c }"}
+	traces := make([]bytes.Buffer, len(inputs))
+	var wg sync.WaitGroup
+	for i, input := range inputs {
+		wg.Add(1)
+		go func(i int, input string) {
+			defer wg.Done()
+			New(lexer.New(input), WithTrace(&traces[i])).ParseProgram()
+		}(i, input)
+	}
+	wg.Wait()
+
+	for i, input := range inputs {
+		var expected bytes.Buffer
+		New(lexer.New(input), WithTrace(&expected)).ParseProgram()
+		if traces[i].String() != expected.String() {
+			t.Errorf("Trace for %q differs when parsed concurrently", input)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Package playground serves Monkey evaluation over HTTP: a JSON request
+// carrying source code is parsed and evaluated with resource limits and
+// the outcome is returned as JSON.
+package playground
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"interpreter/evaluator"
+	"interpreter/interpreter"
+	"interpreter/object"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default limits of a Handler.
+const (
+	DEFAULT_TIMEOUT         = 2 * time.Second
+	DEFAULT_MAX_TIMEOUT     = 10 * time.Second
+	DEFAULT_MAX_SOURCE      = 64 << 10
+	DEFAULT_MAX_CONCURRENCY = 8
+	DEFAULT_MAX_STEPS       = 10_000_000
+	DEFAULT_MAX_MEMORY      = 64 << 20
+	DEFAULT_MAX_OUTPUT      = 1 << 20
+)
+
+// Request is the body of an evaluation request. Timeout is in
+// milliseconds; Stdin is bound to the `stdin` string in the program.
+type Request struct {
+	Source  string `json:"source"`
+	Timeout int64  `json:"timeout"`
+	Stdin   string `json:"stdin"`
+}
+
+// Response reports the outcome of an evaluation. Result is the inspected
+// final value, if any, and Output what the program printed. Errors lists
+// every error message, and Diagnostics the same errors with the phase
+// that produced them.
+type Response struct {
+	Result      *string      `json:"result"`
+	Output      string       `json:"output"`
+	Errors      []string     `json:"errors"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Diagnostic phases.
+const (
+	PHASE_REQUEST = "request"
+	PHASE_PARSE   = "parse"
+	PHASE_RUNTIME = "runtime"
+	PHASE_LIMIT   = "limit"
+)
+
+type Diagnostic struct {
+	Phase   string `json:"phase"`
+	Message string `json:"message"`
+}
+
+// Handler evaluates Requests posted to it. The zero value uses the
+// default limits.
+type Handler struct {
+	// Timeout applies to requests that do not set one.
+	Timeout time.Duration
+	// MaxTimeout caps the timeout a request may ask for.
+	MaxTimeout time.Duration
+	// MaxSource caps the size of the request body in bytes.
+	MaxSource int64
+	// MaxConcurrency caps evaluations running at once; requests beyond it
+	// are rejected with 503 Service Unavailable.
+	MaxConcurrency int
+	// MaxSteps caps the syntax tree nodes an evaluation may evaluate.
+	MaxSteps int
+	// MaxMemory caps the approximate bytes an evaluation's bindings hold.
+	MaxMemory int64
+	// MaxOutput caps the bytes of Output; the rest is dropped.
+	MaxOutput int
+
+	slots chan struct{}
+	once  sync.Once
+	// run runs src with in, replaced in tests
+	run func(ctx context.Context, in *interpreter.Interpreter, src string) (object.Object, error)
+}
+
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "only POST is supported")
+		return
+	}
+
+	var req Request
+	body := http.MaxBytesReader(w, r.Body, h.maxSource())
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request is larger than %d bytes", tooLarge.Limit))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	slots := h.concurrencySlots()
+	select {
+	case slots <- struct{}{}:
+	default:
+		writeError(w, http.StatusServiceUnavailable, "too many evaluations in progress")
+		return
+	}
+	resp := h.evaluate(r.Context(), req, func() { <-slots })
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// evaluate parses and runs req until it finishes, times out or ctx is
+// done, calling release once evaluation has stopped, which may be shortly
+// after evaluate has returned. Programs run in an Interpreter of their own
+// without capability grants, so they cannot reach files, the network or
+// the environment.
+func (h *Handler) evaluate(ctx context.Context, req Request, release func()) *Response {
+	resp := &Response{Errors: []string{}, Diagnostics: []Diagnostic{}}
+
+	output := &output{max: positive(h.MaxOutput, DEFAULT_MAX_OUTPUT)}
+	in := interpreter.New(
+		interpreter.WithStdout(output),
+		interpreter.WithStderr(output),
+		interpreter.WithMaxSteps(positive(h.MaxSteps, DEFAULT_MAX_STEPS)),
+		interpreter.WithMaxMemory(positive(h.MaxMemory, DEFAULT_MAX_MEMORY)),
+	)
+	if err := in.Set("stdin", req.Stdin); err != nil {
+		release()
+		resp.addError(PHASE_REQUEST, err.Error())
+		return resp
+	}
+
+	timeout := h.timeout(req)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	type outcome struct {
+		result object.Object
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer release()
+		run := h.run
+		if run == nil {
+			run = func(ctx context.Context, in *interpreter.Interpreter, src string) (object.Object, error) {
+				return in.Run(ctx, "<playground>", src)
+			}
+		}
+		result, err := run(ctx, in, req.Source)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case evaluated := <-done:
+		var parseErr *interpreter.ParseError
+		var runtimeErr *interpreter.RuntimeError
+		switch err := evaluated.err; {
+		case errors.As(err, &parseErr):
+			for _, msg := range parseErr.Errors {
+				resp.addError(PHASE_PARSE, msg)
+			}
+		case errors.Is(err, evaluator.ErrCanceled):
+			resp.addError(PHASE_LIMIT, fmt.Sprintf("evaluation timed out after %s", timeout))
+		case errors.As(err, &runtimeErr):
+			phase := PHASE_RUNTIME
+			if errors.Is(err, evaluator.ErrStepLimit) || errors.Is(err, evaluator.ErrMemoryLimit) {
+				phase = PHASE_LIMIT
+			}
+			resp.addError(phase, runtimeErr.Message)
+		case err != nil:
+			resp.addError(PHASE_RUNTIME, err.Error())
+		case evaluated.result != nil:
+			result := evaluated.result.Inspect()
+			resp.Result = &result
+		}
+	case <-ctx.Done():
+		resp.addError(PHASE_LIMIT, fmt.Sprintf("evaluation timed out after %s", timeout))
+	}
+	resp.Output = output.String()
+	return resp
+}
+
+// output collects what a program prints, keeping the first max bytes. It
+// may be read while the program is still writing to it.
+type output struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	max int
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if room := o.max - o.buf.Len(); room < len(p) {
+		o.buf.Write(p[:max(room, 0)])
+	} else {
+		o.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+func (o *output) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+func (resp *Response) addError(phase, msg string) {
+	resp.Errors = append(resp.Errors, msg)
+	resp.Diagnostics = append(resp.Diagnostics, Diagnostic{Phase: phase, Message: msg})
+}
+
+func (h *Handler) timeout(req Request) time.Duration {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DEFAULT_TIMEOUT
+	}
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Millisecond
+	}
+	maxTimeout := h.MaxTimeout
+	if maxTimeout <= 0 {
+		maxTimeout = DEFAULT_MAX_TIMEOUT
+	}
+	if timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+	return timeout
+}
+
+func positive[T int | int64](value, def T) T {
+	if value > 0 {
+		return value
+	}
+	return def
+}
+
+func (h *Handler) maxSource() int64 {
+	if h.MaxSource > 0 {
+		return h.MaxSource
+	}
+	return DEFAULT_MAX_SOURCE
+}
+
+func (h *Handler) concurrencySlots() chan struct{} {
+	h.once.Do(func() {
+		n := h.MaxConcurrency
+		if n <= 0 {
+			n = DEFAULT_MAX_CONCURRENCY
+		}
+		h.slots = make(chan struct{}, n)
+	})
+	return h.slots
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	resp := &Response{Errors: []string{}, Diagnostics: []Diagnostic{}}
+	resp.addError(PHASE_REQUEST, msg)
+	writeJSON(w, status, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
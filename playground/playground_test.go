@@ -0,0 +1,186 @@
+package playground
+
+import (
+	"context"
+	"encoding/json"
+	"interpreter/interpreter"
+	"interpreter/object"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func post(t *testing.T, h http.Handler, body string) (int, *Response) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/eval", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected JSON response, got Content-Type %q", ct)
+	}
+	var resp Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("could not decode response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, &resp
+}
+
+func TestEvaluate(t *testing.T) {
+	code, resp := post(t, NewHandler(), `{"source": "let a = 6; a * 7"}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200 got %d", code)
+	}
+	if resp.Result == nil || *resp.Result != "42" {
+		t.Errorf("Expected result 42 got %v", resp.Result)
+	}
+	if len(resp.Errors) != 0 || len(resp.Diagnostics) != 0 {
+		t.Errorf("Expected no errors, got %v %v", resp.Errors, resp.Diagnostics)
+	}
+}
+
+func TestEvaluateStdin(t *testing.T) {
+	_, resp := post(t, NewHandler(), `{"source": "stdin", "stdin": "hello"}`)
+	if resp.Result == nil || *resp.Result != `"hello"` {
+		t.Errorf("Expected stdin to be bound, got %v", resp.Result)
+	}
+}
+
+func TestEvaluateOutput(t *testing.T) {
+	_, resp := post(t, NewHandler(), `{"source": "puts(\"hello\", 1); eputs(2); 3"}`)
+	if resp.Output != "hello\n1\n2\n" {
+		t.Errorf("Expected printed output, got %q", resp.Output)
+	}
+	if resp.Result == nil || *resp.Result != "3" {
+		t.Errorf("Expected result 3 got %v", resp.Result)
+	}
+
+	h := &Handler{MaxOutput: 4}
+	_, resp = post(t, h, `{"source": "puts(\"hello\")"}`)
+	if resp.Output != "hell" {
+		t.Errorf("Expected output cut at 4 bytes, got %q", resp.Output)
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		body        string
+		diagnostics []Diagnostic
+	}{
+		{`{"source": "1 + true"}`, []Diagnostic{{PHASE_RUNTIME, "type mismatch: INTEGER + BOOLEAN"}}},
+		{`{"source": "let a 1;"}`, []Diagnostic{{PHASE_PARSE, "Expected token type to be =, got INT instead!"}}},
+		{`{"source": "readFile(\"/etc/passwd\")"}`, []Diagnostic{{PHASE_RUNTIME, "permission denied: readFile requires fs.read"}}},
+	}
+	for _, test := range tests {
+		code, resp := post(t, NewHandler(), test.body)
+		if code != http.StatusOK {
+			t.Errorf("%s: expected status 200 got %d", test.body, code)
+		}
+		if resp.Result != nil {
+			t.Errorf("%s: expected no result got %q", test.body, *resp.Result)
+		}
+		if !reflect.DeepEqual(resp.Diagnostics, test.diagnostics) {
+			t.Errorf("%s: expected diagnostics %v got %v", test.body, test.diagnostics, resp.Diagnostics)
+		}
+		if len(resp.Errors) != 1 || resp.Errors[0] != test.diagnostics[0].Message {
+			t.Errorf("%s: unexpected errors %v", test.body, resp.Errors)
+		}
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	h := &Handler{MaxSource: 32}
+	tests := []struct {
+		method   string
+		body     string
+		expected int
+	}{
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "not json", http.StatusBadRequest},
+		{http.MethodPost, `{"source": "` + strings.Repeat("1;", 32) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/eval", strings.NewReader(test.body))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != test.expected {
+			t.Errorf("%s %q: expected status %d got %d", test.method, test.body, test.expected, rec.Code)
+		}
+		var resp Response
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if len(resp.Diagnostics) != 1 || resp.Diagnostics[0].Phase != PHASE_REQUEST {
+			t.Errorf("%s %q: expected a request diagnostic, got %v", test.method, test.body, resp.Diagnostics)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		handler *Handler
+		source  string
+		message string
+	}{
+		{&Handler{MaxSteps: 100}, "for (true) {}", "step limit of 100 exceeded"},
+		{&Handler{MaxMemory: 1000}, `let s = "x"; for (true) { s = s + s; }`, "memory limit of 1000 bytes exceeded"},
+	}
+	for _, test := range tests {
+		body, _ := json.Marshal(Request{Source: test.source})
+		_, resp := post(t, test.handler, string(body))
+		want := []Diagnostic{{PHASE_LIMIT, test.message}}
+		if !reflect.DeepEqual(resp.Diagnostics, want) {
+			t.Errorf("%s: expected diagnostics %v got %v", test.source, want, resp.Diagnostics)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+	h := &Handler{run: func(context.Context, *interpreter.Interpreter, string) (object.Object, error) {
+		<-unblock
+		return nil, nil
+	}}
+	_, resp := post(t, h, `{"source": "1", "timeout": 10}`)
+	if len(resp.Diagnostics) != 1 || resp.Diagnostics[0].Phase != PHASE_LIMIT {
+		t.Errorf("Expected a time limit diagnostic, got %v", resp.Diagnostics)
+	}
+}
+
+func TestTimeoutStopsEvaluation(t *testing.T) {
+	h := &Handler{MaxConcurrency: 1}
+	_, resp := post(t, h, `{"source": "for (true) {}", "timeout": 10}`)
+	if len(resp.Diagnostics) != 1 || resp.Diagnostics[0].Phase != PHASE_LIMIT {
+		t.Errorf("Expected a time limit diagnostic, got %v", resp.Diagnostics)
+	}
+	// the loop is canceled, so its slot is freed for the next request
+	deadline := time.Now().Add(time.Second)
+	for {
+		code, resp := post(t, h, `{"source": "1"}`)
+		if code == http.StatusOK {
+			if resp.Result == nil || *resp.Result != "1" {
+				t.Errorf("Expected result 1 got %v", resp.Result)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("slot of the timed out evaluation was not released")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	unblock := make(chan struct{})
+	h := &Handler{MaxConcurrency: 1, run: func(context.Context, *interpreter.Interpreter, string) (object.Object, error) {
+		<-unblock
+		return nil, nil
+	}}
+	post(t, h, `{"source": "1", "timeout": 10}`)
+	code, _ := post(t, h, `{"source": "1"}`)
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while an evaluation is still running, got %d", code)
+	}
+	close(unblock)
+}
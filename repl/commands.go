@@ -0,0 +1,157 @@
+package repl
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/token"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Command is a REPL meta-command such as `:env`, invoked by typing its
+// name at the start of a line followed by optional arguments.
+type Command struct {
+	Name        string
+	Usage       string
+	Description string
+	Run         func(s *Session, args string)
+}
+
+// Commands is a registry of REPL commands keyed by name.
+type Commands struct {
+	commands map[string]Command
+}
+
+func NewCommands() *Commands {
+	return &Commands{commands: make(map[string]Command)}
+}
+
+// DefaultCommands returns a registry holding the built-in commands.
+func DefaultCommands() *Commands {
+	c := NewCommands()
+	c.Register(Command{Name: ":help", Usage: ":help", Description: "list the available commands", Run: helpCommand})
+	c.Register(Command{Name: ":env", Usage: ":env", Description: "list the session bindings", Run: envCommand})
+	c.Register(Command{Name: ":reset", Usage: ":reset", Description: "clear the session bindings", Run: resetCommand})
+	c.Register(Command{Name: ":tokens", Usage: ":tokens <expr>", Description: "print the tokens produced by the lexer", Run: tokensCommand})
+	c.Register(Command{Name: ":ast", Usage: ":ast <expr>", Description: "print the parse tree", Run: astCommand})
+	c.Register(Command{Name: ":dot", Usage: ":dot <expr>", Description: "print the parse tree as a Graphviz DOT graph", Run: dotCommand})
+	c.Register(Command{Name: ":type", Usage: ":type <expr>", Description: "print the type of the evaluated value", Run: typeCommand})
+	c.Register(Command{Name: ":time", Usage: ":time <expr>", Description: "evaluate and report time and process-wide allocations", Run: timeCommand})
+	c.Register(Command{Name: ":load", Usage: ":load <file>", Description: "evaluate a file into the session", Run: loadCommand})
+	return c
+}
+
+// Register adds cmd, replacing any command with the same name.
+func (c *Commands) Register(cmd Command) {
+	c.commands[cmd.Name] = cmd
+}
+
+func (c *Commands) Unregister(name string) {
+	delete(c.commands, name)
+}
+
+func (c *Commands) Lookup(name string) (Command, bool) {
+	cmd, ok := c.commands[name]
+	return cmd, ok
+}
+
+// Names returns the registered command names in sorted order.
+func (c *Commands) Names() []string {
+	names := make([]string, 0, len(c.commands))
+	for name := range c.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func helpCommand(s *Session, args string) {
+	for _, name := range s.Commands.Names() {
+		cmd, _ := s.Commands.Lookup(name)
+		fmt.Fprintf(s.Out, "%-16s %s\n", cmd.Usage, cmd.Description)
+	}
+}
+
+func envCommand(s *Session, args string) {
+	for _, name := range s.Env.Names() {
+		value, _ := s.Env.Get(name)
+		io.WriteString(s.Out, name+" = "+value.Inspect()+"\n")
+	}
+}
+
+func resetCommand(s *Session, args string) {
+	s.Env = object.NewEnclosedEnvironment(s.builtins)
+	if s.Memory != nil {
+		s.Memory.Used = 0
+	}
+}
+
+func tokensCommand(s *Session, args string) {
+	l := lexer.New(args)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.Out, "%-8s %q\n", tok.Type, tok.Literal)
+	}
+}
+
+func astCommand(s *Session, args string) {
+	if program := s.parse(args); program != nil {
+		io.WriteString(s.Out, ast.Tree(program))
+	}
+}
+
+func dotCommand(s *Session, args string) {
+	if program := s.parse(args); program != nil {
+		io.WriteString(s.Out, ast.Dot(program))
+	}
+}
+
+func typeCommand(s *Session, args string) {
+	program := s.parse(args)
+	if program == nil {
+		return
+	}
+	evaluated := s.eval(program)
+	if evaluated == nil {
+		io.WriteString(s.Out, "no value\n")
+		return
+	}
+	io.WriteString(s.Out, string(evaluated.Type())+"\n")
+}
+
+func timeCommand(s *Session, args string) {
+	program := s.parse(args)
+	if program == nil {
+		return
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	evaluated := s.eval(program)
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	// the allocations are the whole process's, other sessions' included
+	s.print(evaluated)
+	fmt.Fprintf(s.Out, "time: %s, process allocations: %d (%d bytes)\n",
+		elapsed, after.Mallocs-before.Mallocs, after.TotalAlloc-before.TotalAlloc)
+}
+
+func loadCommand(s *Session, args string) {
+	path := strings.TrimSpace(args)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(s.Out, "could not load %s: %v\n", path, err)
+		return
+	}
+	program := s.parse(string(src))
+	if program == nil {
+		return
+	}
+	s.print(s.eval(program))
+}
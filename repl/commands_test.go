@@ -0,0 +1,93 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func runSession(s *Session, input string) string {
+	var out bytes.Buffer
+	s.Out = &out
+	s.Run(strings.NewReader(input))
+	return out.String()
+}
+
+func TestTokensCommand(t *testing.T) {
+	out := runSession(NewSession(nil), ":tokens let a = 1;\n")
+	expected := "LET      \"let\"\nIDENT    \"a\"\n=        \"=\"\nINT      \"1\"\n;        \";\"\n"
+	if out != expected {
+		t.Errorf("Expected :tokens output %q got %q", expected, out)
+	}
+}
+
+func TestAstCommand(t *testing.T) {
+	out := runSession(NewSession(nil), ":ast 1 + 2\n")
+	expected := `Program
+  0: ExpressionStatement
+    expression: InfixExpression (operator: +)
+      left: IntegerLiteral (literal: 1)
+      right: IntegerLiteral (literal: 2)
+`
+	if out != expected {
+		t.Errorf("Expected :ast output %q got %q", expected, out)
+	}
+}
+
+func TestTypeCommand(t *testing.T) {
+	out := runSession(NewSession(nil), "let a = 1;\n:type a\n:type a > 0\n:type b\n")
+	expected := "INTEGER\nBOOLEAN\nERROR\n"
+	if out != expected {
+		t.Errorf("Expected :type output %q got %q", expected, out)
+	}
+}
+
+func TestTimeCommand(t *testing.T) {
+	out := runSession(NewSession(nil), ":time 2 * 21\n")
+	if !regexp.MustCompile(`^42\ntime: \S+, process allocations: \d+ \(\d+ bytes\)\n$`).MatchString(out) {
+		t.Errorf("Unexpected :time output %q", out)
+	}
+}
+
+func TestLoadCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.mk")
+	if err := os.WriteFile(path, []byte("let a = 2;\nlet b = a * 3;\nb\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	out := runSession(NewSession(nil), ":load "+path+"\na + b\n:load missing.mk\n")
+	if !strings.HasPrefix(out, "6\n8\ncould not load missing.mk") {
+		t.Errorf("Unexpected :load output %q", out)
+	}
+}
+
+func TestHelpCommand(t *testing.T) {
+	out := runSession(NewSession(nil), ":help\n")
+	for _, name := range DefaultCommands().Names() {
+		if !strings.Contains(out, name) {
+			t.Errorf("Expected :help to list %s, got %q", name, out)
+		}
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	out := runSession(NewSession(nil), ":nope\n")
+	if !strings.HasPrefix(out, "unknown command :nope") {
+		t.Errorf("Unexpected output for an unknown command %q", out)
+	}
+}
+
+func TestRegisterCommand(t *testing.T) {
+	s := NewSession(nil)
+	s.Commands.Register(Command{
+		Name: ":echo",
+		Run: func(s *Session, args string) {
+			s.Out.Write([]byte(args + "\n"))
+		},
+	})
+	if out := runSession(s, ":echo hello\n"); out != "hello\n" {
+		t.Errorf("Expected registered command to run, got %q", out)
+	}
+}
@@ -0,0 +1,59 @@
+package repl
+
+import (
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/token"
+	"sort"
+	"strings"
+)
+
+// complete returns the word ending at the end of text, which is the line
+// up to the cursor, and the sorted candidates that could replace it: REPL
+// commands at the start of the line, otherwise keywords and names bound
+// in env or its outer scopes, such as builtins.
+func complete(text string, env *object.Environment, commands []string) (string, []string) {
+	if strings.HasPrefix(text, ":") {
+		if strings.ContainsAny(text, " \t") {
+			return "", nil
+		}
+		return text, matching(text, commands)
+	}
+
+	prefix := ""
+	l := lexer.New(text)
+	var last token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		last = tok
+	}
+	if last.Literal != "" && strings.HasSuffix(text, last.Literal) {
+		if !isWordToken(last) {
+			return "", nil
+		}
+		prefix = last.Literal
+	}
+
+	words := token.Keywords()
+	for scope := env; scope != nil; scope = scope.Outer() {
+		words = append(words, scope.Names()...)
+	}
+	return prefix, matching(prefix, words)
+}
+
+// isWordToken reports whether tok is an identifier or keyword.
+func isWordToken(tok token.Token) bool {
+	return token.LookupIdent(tok.Literal) == tok.Type
+}
+
+func matching(prefix string, words []string) []string {
+	seen := map[string]bool{}
+	var candidates []string
+	for _, word := range words {
+		if strings.HasPrefix(word, prefix) && !seen[word] {
+			seen[word] = true
+			candidates = append(candidates, word)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
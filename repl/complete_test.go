@@ -0,0 +1,97 @@
+package repl
+
+import (
+	"interpreter/object"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("result", &object.Boolean{Value: true})
+	env.Set("retries", &object.Boolean{Value: true})
+	env.Set("limit", &object.Boolean{Value: true})
+
+	tests := []struct {
+		input      string
+		word       string
+		candidates []string
+	}{
+		{"re", "re", []string{"result", "retries", "return"}},
+		{"let a = lim", "lim", []string{"limit"}},
+		{"if (tr", "tr", []string{"true"}},
+		{"le", "le", []string{"let"}},
+		{"1 + 2", "", nil},
+		{"x", "x", nil},
+		{":", ":", []string{":dot", ":env", ":reset"}},
+		{":re", ":re", []string{":reset"}},
+		{":dot le", "", nil},
+	}
+	for _, test := range tests {
+		word, candidates := complete(test.input, env, []string{":reset", ":dot", ":env"})
+		if word != test.word {
+			t.Errorf("complete(%q) expected word %q got %q", test.input, test.word, word)
+		}
+		if !reflect.DeepEqual(candidates, test.candidates) {
+			t.Errorf("complete(%q) expected candidates %v got %v", test.input, test.candidates, candidates)
+		}
+	}
+}
+
+func TestCompleteBuiltins(t *testing.T) {
+	s := NewSession(nil)
+	s.Env.Set("lenient", &object.Boolean{Value: true})
+	word, candidates := s.complete("pu")
+	if word != "pu" || !reflect.DeepEqual(candidates, []string{"puts"}) {
+		t.Errorf("complete(%q) expected puts got %q %v", "pu", word, candidates)
+	}
+	_, candidates = s.complete("le")
+	if expected := []string{"len", "lenient", "let"}; !reflect.DeepEqual(candidates, expected) {
+		t.Errorf("complete(%q) expected candidates %v got %v", "le", expected, candidates)
+	}
+}
+
+func TestCompleteAfterSpace(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("zeta", &object.Boolean{Value: true})
+	word, candidates := complete("let a = ", env, nil)
+	if word != "" {
+		t.Errorf("Expected empty word after a space, got %q", word)
+	}
+	if len(candidates) == 0 || candidates[len(candidates)-1] != "zeta" {
+		t.Errorf("Expected all keywords and bindings as candidates, got %v", candidates)
+	}
+}
+
+func TestEditorTabCompletion(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("counter", &object.Boolean{Value: true})
+	env.Set("count", &object.Boolean{Value: true})
+
+	tests := []struct {
+		keys     string
+		expected string
+		listed   bool
+	}{
+		{"cou\t\r", "count", false},
+		{"counte\t + 1\r", "counter + 1", false},
+		{"cou\t\t\r", "count", true},
+		{"let x = co\x1b[D\tX\r", "let x = countXo", false},
+	}
+	for _, test := range tests {
+		var out strings.Builder
+		e := NewEditor(strings.NewReader(test.keys), &out, nil)
+		e.Completer = func(line string) (string, []string) { return complete(line, env, nil) }
+		line, err := e.ReadLine(PROMPT)
+		if err != nil {
+			t.Fatalf("ReadLine(%q) returned error %v", test.keys, err)
+		}
+		if line != test.expected {
+			t.Errorf("ReadLine(%q) expected %q got %q", test.keys, test.expected, line)
+		}
+		if listed := strings.Contains(out.String(), "count  counter"); listed != test.listed {
+			t.Errorf("ReadLine(%q) expected candidates listed: %t, output %q", test.keys, test.listed, out.String())
+		}
+	}
+}
@@ -0,0 +1,439 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// ErrInterrupted is returned by Editor.ReadLine when the user presses Ctrl-C.
+var ErrInterrupted = errors.New("interrupted")
+
+const (
+	ctrlA     = 1
+	ctrlB     = 2
+	ctrlC     = 3
+	ctrlD     = 4
+	ctrlE     = 5
+	ctrlF     = 6
+	ctrlG     = 7
+	ctrlH     = 8
+	tab       = 9
+	ctrlK     = 11
+	ctrlL     = 12
+	ctrlN     = 14
+	ctrlP     = 16
+	ctrlR     = 18
+	ctrlU     = 21
+	ctrlW     = 23
+	escape    = 27
+	backspace = 127
+)
+
+type keyKind int
+
+const (
+	keyRune keyKind = iota
+	keyControl
+	keyEnter
+	keyLeft
+	keyRight
+	keyUp
+	keyDown
+	keyHome
+	keyEnd
+	keyDelete
+	keyWordLeft
+	keyWordRight
+	keyWordBackspace
+	keyUnknown
+)
+
+type key struct {
+	kind keyKind
+	r    rune
+}
+
+// Editor is a small line editor for ANSI terminals. It supports cursor
+// movement, word deletion, history navigation and Ctrl-R reverse search.
+type Editor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history *History
+	// Completer is called on Tab with the line up to the cursor. It returns
+	// the word before the cursor and the candidates that may replace it.
+	Completer func(line string) (string, []string)
+	// fd is the terminal switched to raw mode while reading, -1 for none.
+	fd int
+}
+
+func NewEditor(in io.Reader, out io.Writer, history *History) *Editor {
+	if history == nil {
+		history = &History{}
+	}
+	return &Editor{in: bufio.NewReader(in), out: out, history: history, fd: -1}
+}
+
+// lineState is the line being edited and the cursor position within it.
+type lineState struct {
+	prompt string
+	buf    []rune
+	pos    int
+	// index into the history while browsing it, history.Len() otherwise
+	index int
+	// the line as typed before browsing the history
+	saved []rune
+}
+
+// ReadLine shows prompt and returns the edited line once Enter is pressed.
+// It returns io.EOF on Ctrl-D over an empty line and ErrInterrupted on
+// Ctrl-C. Lines read are added to the history.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.fd >= 0 {
+		restore, err := enableRawMode(e.fd)
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	s := &lineState{prompt: prompt, index: e.history.Len()}
+	e.refresh(s)
+	for {
+		k, err := e.readKey()
+		if err == io.EOF && len(s.buf) != 0 {
+			k, err = key{kind: keyEnter}, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if k.kind == keyControl && k.r == ctrlR {
+			if k, err = e.reverseSearch(s); err != nil {
+				return "", err
+			}
+		}
+
+		switch {
+		case k.kind == keyEnter:
+			io.WriteString(e.out, "\r\n")
+			line := string(s.buf)
+			e.history.Add(line)
+			return line, nil
+		case k.kind == keyControl && k.r == ctrlC:
+			io.WriteString(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case k.kind == keyControl && k.r == ctrlD:
+			if len(s.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			s.deleteRight()
+		case k.kind == keyControl && k.r == ctrlL:
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case k.kind == keyControl && k.r == tab:
+			e.complete(s)
+		default:
+			e.edit(s, k)
+		}
+		e.refresh(s)
+	}
+}
+
+// edit applies a key which only changes the line being edited.
+func (e *Editor) edit(s *lineState, k key) {
+	switch k.kind {
+	case keyRune:
+		s.buf = append(s.buf[:s.pos], append([]rune{k.r}, s.buf[s.pos:]...)...)
+		s.pos++
+	case keyLeft:
+		if s.pos > 0 {
+			s.pos--
+		}
+	case keyRight:
+		if s.pos < len(s.buf) {
+			s.pos++
+		}
+	case keyHome:
+		s.pos = 0
+	case keyEnd:
+		s.pos = len(s.buf)
+	case keyDelete:
+		s.deleteRight()
+	case keyWordLeft:
+		s.pos = s.wordStart()
+	case keyWordRight:
+		s.pos = s.wordEnd()
+	case keyWordBackspace:
+		start := s.wordStart()
+		s.buf = append(s.buf[:start], s.buf[s.pos:]...)
+		s.pos = start
+	case keyUp:
+		e.browseHistory(s, -1)
+	case keyDown:
+		e.browseHistory(s, 1)
+	case keyControl:
+		switch k.r {
+		case ctrlA:
+			e.edit(s, key{kind: keyHome})
+		case ctrlE:
+			e.edit(s, key{kind: keyEnd})
+		case ctrlB:
+			e.edit(s, key{kind: keyLeft})
+		case ctrlF:
+			e.edit(s, key{kind: keyRight})
+		case ctrlP:
+			e.edit(s, key{kind: keyUp})
+		case ctrlN:
+			e.edit(s, key{kind: keyDown})
+		case ctrlW:
+			e.edit(s, key{kind: keyWordBackspace})
+		case ctrlH, backspace:
+			if s.pos > 0 {
+				s.buf = append(s.buf[:s.pos-1], s.buf[s.pos:]...)
+				s.pos--
+			}
+		case ctrlK:
+			s.buf = s.buf[:s.pos]
+		case ctrlU:
+			s.buf = s.buf[s.pos:]
+			s.pos = 0
+		}
+	}
+}
+
+// complete replaces the word before the cursor with the only candidate,
+// or with the longest prefix shared by all candidates. When that makes no
+// progress the candidates are listed below the line.
+func (e *Editor) complete(s *lineState) {
+	if e.Completer == nil {
+		return
+	}
+	word, candidates := e.Completer(string(s.buf[:s.pos]))
+	if len(candidates) == 0 {
+		io.WriteString(e.out, "\a")
+		return
+	}
+	replacement := candidates[0]
+	for _, candidate := range candidates[1:] {
+		replacement = commonPrefix(replacement, candidate)
+	}
+	if replacement == word && len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+		return
+	}
+	start := s.pos - len([]rune(word))
+	tail := append([]rune(replacement), s.buf[s.pos:]...)
+	s.buf = append(s.buf[:start], tail...)
+	s.pos = start + len([]rune(replacement))
+}
+
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
+
+func (s *lineState) deleteRight() {
+	if s.pos < len(s.buf) {
+		s.buf = append(s.buf[:s.pos], s.buf[s.pos+1:]...)
+	}
+}
+
+func (s *lineState) wordStart() int {
+	i := s.pos
+	for i > 0 && !isWordRune(s.buf[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(s.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+func (s *lineState) wordEnd() int {
+	i := s.pos
+	for i < len(s.buf) && !isWordRune(s.buf[i]) {
+		i++
+	}
+	for i < len(s.buf) && isWordRune(s.buf[i]) {
+		i++
+	}
+	return i
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func (e *Editor) browseHistory(s *lineState, step int) {
+	index := s.index + step
+	if index < 0 || index > e.history.Len() {
+		return
+	}
+	if s.index == e.history.Len() {
+		s.saved = append([]rune{}, s.buf...)
+	}
+	s.index = index
+	if index == e.history.Len() {
+		s.buf = append([]rune{}, s.saved...)
+	} else {
+		s.buf = []rune(e.history.At(index))
+	}
+	s.pos = len(s.buf)
+}
+
+// reverseSearch runs an incremental Ctrl-R search through the history.
+// Typing narrows the query and Ctrl-R jumps to older matches. Any other
+// key accepts the match into the line and is returned to be handled by
+// ReadLine, except Ctrl-G which restores the original line.
+func (e *Editor) reverseSearch(s *lineState) (key, error) {
+	var query []rune
+	match := s.index
+	found := true
+	original := append([]rune{}, s.buf...)
+
+	search := func(from int) {
+		for i := from; i >= 0; i-- {
+			if i < e.history.Len() && containsRunes(e.history.At(i), query) {
+				match, found = i, true
+				return
+			}
+		}
+		found = false
+	}
+
+	for {
+		status := "reverse-i-search"
+		if !found {
+			status = "failing reverse-i-search"
+		}
+		line := ""
+		if match < e.history.Len() {
+			line = e.history.At(match)
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", status, string(query), line)
+
+		k, err := e.readKey()
+		if err != nil {
+			return k, err
+		}
+		switch {
+		case k.kind == keyRune:
+			query = append(query, k.r)
+			search(match)
+		case k.kind == keyControl && (k.r == backspace || k.r == ctrlH):
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+			}
+			search(e.history.Len() - 1)
+		case k.kind == keyControl && k.r == ctrlR:
+			search(match - 1)
+		case k.kind == keyControl && k.r == ctrlG:
+			s.buf = original
+			s.pos = len(s.buf)
+			return key{kind: keyUnknown}, nil
+		default:
+			if match < e.history.Len() {
+				s.buf = []rune(e.history.At(match))
+				s.index = match
+			}
+			s.pos = len(s.buf)
+			return k, nil
+		}
+	}
+}
+
+func containsRunes(s string, sub []rune) bool {
+	return len(sub) == 0 || indexRunes([]rune(s), sub) >= 0
+}
+
+func indexRunes(s []rune, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if string(s[i:i+len(sub)]) == string(sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// refresh redraws the prompt and line and places the cursor.
+func (e *Editor) refresh(s *lineState) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", s.prompt, string(s.buf))
+	if back := len(s.buf) - s.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func (e *Editor) readKey() (key, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+	switch {
+	case r == '\r' || r == '\n':
+		return key{kind: keyEnter}, nil
+	case r == escape:
+		return e.readEscape()
+	case r < 32 || r == backspace:
+		return key{kind: keyControl, r: r}, nil
+	}
+	return key{kind: keyRune, r: r}, nil
+}
+
+// readEscape decodes the escape sequences sent by common terminals for
+// arrow, home/end and delete keys, and Alt-modified keys.
+func (e *Editor) readEscape() (key, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+	switch r {
+	case 'b':
+		return key{kind: keyWordLeft}, nil
+	case 'f':
+		return key{kind: keyWordRight}, nil
+	case backspace, ctrlH:
+		return key{kind: keyWordBackspace}, nil
+	case '[', 'O':
+	default:
+		return key{kind: keyUnknown}, nil
+	}
+
+	var seq []rune
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return key{}, err
+		}
+		seq = append(seq, r)
+		if r >= '@' && r <= '~' {
+			break
+		}
+	}
+	switch string(seq) {
+	case "A":
+		return key{kind: keyUp}, nil
+	case "B":
+		return key{kind: keyDown}, nil
+	case "C":
+		return key{kind: keyRight}, nil
+	case "D":
+		return key{kind: keyLeft}, nil
+	case "H", "1~", "7~":
+		return key{kind: keyHome}, nil
+	case "F", "4~", "8~":
+		return key{kind: keyEnd}, nil
+	case "3~":
+		return key{kind: keyDelete}, nil
+	case "1;5C", "1;3C":
+		return key{kind: keyWordRight}, nil
+	case "1;5D", "1;3D":
+		return key{kind: keyWordLeft}, nil
+	}
+	return key{kind: keyUnknown}, nil
+}
@@ -0,0 +1,121 @@
+package repl
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEditorEditing(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"let a = 1;\r", "let a = 1;"},
+		{"ac\x1b[DB\r", "aBc"},
+		{"bc\x01a\x05d\r", "abcd"},
+		{"abc\x7f\x7f\r", "a"},
+		{"let foo\x17bar\r", "let bar"},
+		{"one two\x1bbX\r", "one Xtwo"},
+		{"one two\x1b[1;5D\x1b[1;5DX\x1b[1;5CY\r", "XoneY two"},
+		{"abcd\x1b[D\x1b[D\x0b\r", "ab"},
+		{"abcd\x1b[D\x15\r", "d"},
+		{"abc\x1b[H\x1b[3~\r", "bc"},
+		{"héllo\x1b[D\x1b[D\x1b[D\x1b[D\x7f\r", "éllo"},
+	}
+	for _, test := range tests {
+		e := NewEditor(strings.NewReader(test.keys), io.Discard, nil)
+		line, err := e.ReadLine(PROMPT)
+		if err != nil {
+			t.Fatalf("ReadLine(%q) returned error %v", test.keys, err)
+		}
+		if line != test.expected {
+			t.Errorf("ReadLine(%q) expected %q got %q", test.keys, test.expected, line)
+		}
+	}
+}
+
+func TestEditorControlKeys(t *testing.T) {
+	e := NewEditor(strings.NewReader("1 +\x03\x04"), io.Discard, nil)
+	if _, err := e.ReadLine(PROMPT); err != ErrInterrupted {
+		t.Errorf("Expected Ctrl-C to return ErrInterrupted, got %v", err)
+	}
+	if _, err := e.ReadLine(PROMPT); err != io.EOF {
+		t.Errorf("Expected Ctrl-D on an empty line to return io.EOF, got %v", err)
+	}
+}
+
+func TestEditorHistory(t *testing.T) {
+	keys := "first\rsecond\r\x1b[A\x1b[A\r\x1b[A\x1b[A\x1b[A\x1b[Bedit\rtyped\x1b[A\x1b[B\r"
+	e := NewEditor(strings.NewReader(keys), io.Discard, nil)
+	expected := []string{"first", "second", "first", "secondedit", "typed"}
+	for _, want := range expected {
+		line, err := e.ReadLine(PROMPT)
+		if err != nil {
+			t.Fatalf("ReadLine returned error %v", err)
+		}
+		if line != want {
+			t.Errorf("Expected %q got %q", want, line)
+		}
+	}
+	if e.history.Len() != 5 {
+		t.Errorf("Expected 5 history entries, got %d", e.history.Len())
+	}
+}
+
+func TestEditorReverseSearch(t *testing.T) {
+	history := &History{}
+	for _, line := range []string{"let apple = 1;", "let banana = 2;", "apple + banana"} {
+		history.Add(line)
+	}
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"\x12ban\r", "apple + banana"},
+		{"\x12ban\x12\r", "let banana = 2;"},
+		{"\x12apple\x12\x1b[D\x7f\r", "let apple = ;"},
+		{"x\x12zzz\x07\r", "x"},
+	}
+	for _, test := range tests {
+		e := NewEditor(strings.NewReader(test.keys), io.Discard, history)
+		line, err := e.ReadLine(PROMPT)
+		if err != nil {
+			t.Fatalf("ReadLine(%q) returned error %v", test.keys, err)
+		}
+		if line != test.expected {
+			t.Errorf("ReadLine(%q) expected %q got %q", test.keys, test.expected, line)
+		}
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HISTORY_FILE)
+	history, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("LoadHistory on a missing file returned error %v", err)
+	}
+	for _, line := range []string{"let a = 1;", "", "let a = 1;", "a * 2"} {
+		if err := history.Add(line); err != nil {
+			t.Fatalf("Add(%q) returned error %v", line, err)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read history file: %v", err)
+	}
+	if string(content) != "let a = 1;\na * 2\n" {
+		t.Errorf("Unexpected history file content %q", content)
+	}
+
+	reloaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("LoadHistory returned error %v", err)
+	}
+	if reloaded.Len() != 2 || reloaded.At(1) != "a * 2" {
+		t.Errorf("Expected reloaded history to hold the saved lines, got %v", reloaded.entries)
+	}
+}
@@ -0,0 +1,77 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const HISTORY_FILE = ".monkey_history"
+const HISTORY_SIZE = 1000
+
+// History holds previously entered lines, oldest first, and appends new
+// entries to a file when one is attached.
+type History struct {
+	entries []string
+	path    string
+}
+
+// LoadHistory reads the history stored at path. A missing file is not an
+// error; lines added later create it.
+func LoadHistory(path string) (*History, error) {
+	h := &History{path: path}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		h.push(scanner.Text())
+	}
+	return h, scanner.Err()
+}
+
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, HISTORY_FILE)
+}
+
+func (h *History) Len() int { return len(h.entries) }
+
+func (h *History) At(i int) string { return h.entries[i] }
+
+// Add records line unless it is blank or repeats the latest entry.
+func (h *History) Add(line string) error {
+	if strings.TrimSpace(line) == "" || strings.Contains(line, "\n") {
+		return nil
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == line {
+		return nil
+	}
+	h.push(line)
+	if h.path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(line + "\n")
+	return err
+}
+
+func (h *History) push(line string) {
+	h.entries = append(h.entries, line)
+	if len(h.entries) > HISTORY_SIZE {
+		h.entries = h.entries[len(h.entries)-HISTORY_SIZE:]
+	}
+}
@@ -0,0 +1,79 @@
+package repl
+
+import (
+	"interpreter/lexer"
+	"interpreter/token"
+)
+
+// continuationTokens are tokens that cannot end a complete input, such as
+// a dangling operator or a keyword still waiting for its operands.
+var continuationTokens = map[token.Type]bool{
+	token.ASSIGN:   true,
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.BANG:     true,
+	token.ASTERISK: true,
+	token.SLASH:    true,
+	token.LT:       true,
+	token.GT:       true,
+	token.EQ:       true,
+	token.NEQ:      true,
+	token.COMMA:    true,
+	token.FUNCTION: true,
+	token.LET:      true,
+	token.IF:       true,
+	token.ELSE:     true,
+	token.RETURN:   true,
+	token.FOR:      true,
+}
+
+// isIncomplete reports whether input needs more lines before it can be
+// parsed: it has unclosed parentheses or braces, ends inside a string or
+// ends on a token that expects something to follow.
+func isIncomplete(input string) bool {
+	if inString(input) {
+		return true
+	}
+	l := lexer.New(input)
+	depth := 0
+	var last token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACKET:
+			depth--
+		}
+		last = tok
+	}
+	if depth > 0 {
+		return true
+	}
+	return depth == 0 && continuationTokens[last.Type]
+}
+
+// inString reports whether input ends inside a string literal, which the
+// lexer would return as an illegal token. A string with an unknown escape
+// is illegal whatever follows, so more lines would not complete it.
+func inString(input string) bool {
+	in := false
+	for i := 0; i < len(input); i++ {
+		switch c := input[i]; {
+		case !in:
+			in = c == '"'
+		case c == '"':
+			in = false
+		case c == '\\':
+			if i+1 == len(input) {
+				return true
+			}
+			switch input[i+1] {
+			case '"', '\\', 'n', 't':
+				i++
+			default:
+				return false
+			}
+		}
+	}
+	return in
+}
@@ -2,14 +2,22 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"interpreter/ast"
 	"interpreter/evaluator"
+	"interpreter/interpreter"
 	"interpreter/lexer"
+	"interpreter/object"
 	"interpreter/parser"
 	"io"
+	"os"
+	"strings"
 )
 
 const PROMPT = ">>"
+const CONTINUATION_PROMPT = ".."
+
 const MONKEY_FACE = ` __,__
     .--. .-" "-. .--.
   / .. \/ .-. .-. \/ .. \
@@ -24,27 +32,185 @@ const MONKEY_FACE = ` __,__
 `
 
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+	NewSession(out).Run(in)
+}
+
+// Session is the state of one REPL: the environment holding the bindings
+// made so far, enclosed in a scope of the interpreter package's builtins,
+// the writer results go to and the available commands.
+type Session struct {
+	Env      *object.Environment
+	Out      io.Writer
+	Commands *Commands
+	// Prompts shows prompts on Out even when the input is not a terminal,
+	// as network clients expect.
+	Prompts bool
+	// MaxSteps caps the nodes each evaluation may take, zero for no limit.
+	MaxSteps int
+	// Memory, if set, accounts the bytes bound in Env and enforces its
+	// limit.
+	Memory *evaluator.Memory
+
+	ctx      context.Context
+	builtins *object.Environment
+}
+
+func NewSession(out io.Writer) *Session {
+	s := &Session{
+		Out:      out,
+		Commands: DefaultCommands(),
+	}
+	// puts and eputs print to whatever Out is when they are called
+	s.builtins = interpreter.New(interpreter.WithStdout(output{s}), interpreter.WithStderr(output{s})).Builtins()
+	s.Env = object.NewEnclosedEnvironment(s.builtins)
+	return s
+}
+
+// output writes to the Out of a session.
+type output struct{ s *Session }
+
+func (o output) Write(p []byte) (int, error) {
+	return o.s.Out.Write(p)
+}
+
+// Run reads and evaluates lines from in until it is exhausted.
+func (s *Session) Run(in io.Reader) {
+	s.RunContext(context.Background(), in)
+}
+
+// RunContext is Run stopping any evaluation in progress once ctx is done.
+func (s *Session) RunContext(ctx context.Context, in io.Reader) {
+	s.ctx = ctx
+	defer func() { s.ctx = nil }()
+	lines := newLineReader(in, s.Out, s.complete)
+	if r, ok := lines.(*scannerReader); ok && s.Prompts {
+		r.prompts = s.Out
+	}
+	var pending []string
 	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		prompt := PROMPT
+		if len(pending) != 0 {
+			prompt = CONTINUATION_PROMPT
+		}
+		line, err := lines.ReadLine(prompt)
+		// Ctrl-D, like Ctrl-C, only discards pending input, ending the
+		// session over an empty prompt
+		if err == ErrInterrupted || (err == io.EOF && len(pending) != 0) {
+			pending = nil
+			continue
+		}
+		if err != nil {
 			return
 		}
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if len(pending) != 0 {
+			if strings.TrimSpace(line) == "" {
+				pending = nil
+				continue
+			}
+		} else if strings.HasPrefix(line, ":") {
+			s.runCommand(line)
 			continue
 		}
-		evaluated := evaluator.Eval(program)
-		if nil != evaluated {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+
+		pending = append(pending, line)
+		input := strings.Join(pending, "\n")
+		if isIncomplete(input) {
+			continue
+		}
+		pending = nil
+
+		if program := s.parse(input); program != nil {
+			s.print(s.eval(program))
+		}
+	}
+}
+
+// eval evaluates program in the session's environment within its limits.
+func (s *Session) eval(program *ast.Program) object.Object {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	e := evaluator.New(ctx)
+	e.MaxSteps = s.MaxSteps
+	e.Memory = s.Memory
+	return e.Eval(program, s.Env)
+}
+
+func (s *Session) runCommand(line string) {
+	name, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+	cmd, ok := s.Commands.Lookup(name)
+	if !ok {
+		fmt.Fprintf(s.Out, "unknown command %s, type :help for a list of commands\n", name)
+		return
+	}
+	cmd.Run(s, args)
+}
+
+// parse parses input, printing any parser errors and returning nil
+// when there were some.
+func (s *Session) parse(input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.Out, p.Errors())
+		return nil
+	}
+	return program
+}
+
+func (s *Session) print(evaluated object.Object) {
+	if nil != evaluated {
+		io.WriteString(s.Out, evaluated.Inspect())
+		io.WriteString(s.Out, "\n")
+	}
+}
+
+func (s *Session) complete(line string) (string, []string) {
+	return complete(line, s.Env, s.Commands.Names())
+}
+
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// IsTerminal reports whether in is a terminal the line editor can drive.
+func IsTerminal(in io.Reader) bool {
+	f, ok := in.(*os.File)
+	return ok && isTerminal(int(f.Fd()))
+}
+
+// newLineReader returns a line Editor with persistent history when in is
+// a terminal, and a plain line scanner, which shows no prompts, otherwise.
+func newLineReader(in io.Reader, out io.Writer, completer func(string) (string, []string)) lineReader {
+	if IsTerminal(in) {
+		f := in.(*os.File)
+		history, _ := LoadHistory(defaultHistoryPath())
+		editor := NewEditor(f, out, history)
+		editor.fd = int(f.Fd())
+		editor.Completer = completer
+		return editor
+	}
+	return &scannerReader{scanner: bufio.NewScanner(in)}
+}
+
+type scannerReader struct {
+	scanner *bufio.Scanner
+	// prompts receives the prompts, nil to hide them
+	prompts io.Writer
+}
+
+func (r *scannerReader) ReadLine(prompt string) (string, error) {
+	if r.prompts != nil {
+		io.WriteString(r.prompts, prompt)
+	}
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
 		}
+		return "", io.EOF
 	}
+	return r.scanner.Text(), nil
 }
 
 func printParserErrors(out io.Writer, errors []string) {
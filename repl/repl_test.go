@@ -0,0 +1,114 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSessionKeepsBindings(t *testing.T) {
+	input := "let a = 5;\nlet b = a * 2;\na + b\n"
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	if out.String() != "15\n" {
+		t.Errorf("Expected bindings to survive between lines, got %q", out.String())
+	}
+}
+
+func TestEnvCommand(t *testing.T) {
+	input := "let b = true;\nlet a = 1 + 2;\n:env\n"
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	expected := "a = 3\nb = true\n"
+	if out.String() != expected {
+		t.Errorf("Expected :env output %q got %q", expected, out.String())
+	}
+}
+
+func TestResetCommand(t *testing.T) {
+	input := "let a = 5;\n:reset\n:env\na\n"
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	expected := `Error : "identifier not found: a"` + "\n"
+	if out.String() != expected {
+		t.Errorf("Expected bindings to be cleared by :reset, got %q", out.String())
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	input := `puts("a", len("four"));` + "\n:reset\nlen(\"ab\")\n"
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	expected := "a\n4\nnull\n2\n"
+	if out.String() != expected {
+		t.Errorf("Expected builtins before and after :reset, %q got %q", expected, out.String())
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := "let a = 5;\nif (a > 1) {\n  a * 2\n} else {\n  0\n}\n1 +\n2\n"
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	if out.String() != "10\n3\n" {
+		t.Errorf("Expected multi-line input to be evaluated once complete, got %q", out.String())
+	}
+}
+
+func TestMultiLineString(t *testing.T) {
+	input := "let s = \"a\nb\"; s\n"
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	if out.String() != `"a\nb"`+"\n" {
+		t.Errorf("Expected a string spanning lines to be evaluated once closed, got %q", out.String())
+	}
+}
+
+func TestBlankLineCancelsPendingInput(t *testing.T) {
+	input := "if (true) {\n\n5\n"
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	if out.String() != "5\n" {
+		t.Errorf("Expected blank line to discard pending input, got %q", out.String())
+	}
+}
+
+func TestEndOfInputCancelsPendingInput(t *testing.T) {
+	var out bytes.Buffer
+	s := NewSession(&out)
+	s.Prompts = true
+	s.Run(strings.NewReader("if (true) {\n"))
+	if out.String() != PROMPT+CONTINUATION_PROMPT+PROMPT {
+		t.Errorf("Expected end of input to discard pending input and prompt again, got %q", out.String())
+	}
+}
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2", false},
+		{"1 +", true},
+		{"let a =", true},
+		{"if (a) {", true},
+		{"if (a) { 1 } else", true},
+		{"(1 + (2", true},
+		{"fn(x, y) { x + y }", false},
+		{"fn(x,", true},
+		{"1 + 2)", false},
+		{"}", false},
+		{"", false},
+		{`let s = "abc`, true},
+		{`puts("a {`, true},
+		{`"a\"`, true},
+		{`"a\`, true},
+		{`"a\\"`, false},
+		{`"a\q"`, false},
+		{`"a" + "b"`, false},
+	}
+	for _, test := range tests {
+		if got := isIncomplete(test.input); got != test.expected {
+			t.Errorf("isIncomplete(%q) expected %t got %t", test.input, test.expected, got)
+		}
+	}
+}
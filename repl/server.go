@@ -0,0 +1,153 @@
+package repl
+
+import (
+	"context"
+	"errors"
+	"interpreter/evaluator"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const SERVER_BANNER = "Monkey REPL session, type :help for commands\n"
+
+// Limits of network sessions unless a Server sets its own.
+const (
+	DEFAULT_SESSION_MAX_STEPS  = 10_000_000
+	DEFAULT_SESSION_MAX_MEMORY = 64 << 20
+)
+
+// Server runs a REPL session for every connection accepted on a listener.
+// Each session has its own environment and writes only to its connection.
+type Server struct {
+	// SessionTimeout closes a connection this long after it was accepted,
+	// zero for no limit.
+	SessionTimeout time.Duration
+	// IdleTimeout closes a connection when no line arrives for this long,
+	// zero for no limit.
+	IdleTimeout time.Duration
+	// MaxSteps caps the nodes each evaluation may take, by default
+	// DEFAULT_SESSION_MAX_STEPS; negative means no limit.
+	MaxSteps int
+	// MaxMemory caps the bytes the bindings of a session may hold, by
+	// default DEFAULT_SESSION_MAX_MEMORY; negative means no limit.
+	MaxMemory int64
+	// Commands returns the commands of a new session. It defaults to
+	// DefaultCommands without :load, which would read server files.
+	Commands func() *Commands
+}
+
+// Serve accepts connections on l with a default Server until l is closed.
+func Serve(l net.Listener) error {
+	return (&Server{}).Serve(l)
+}
+
+// Serve accepts connections on l until l is closed, handling each one in
+// its own goroutine. Once l is closed the open sessions are closed too and
+// Serve returns when they have finished.
+func (srv *Server) Serve(l net.Listener) error {
+	var mu sync.Mutex
+	conns := map[net.Conn]bool{}
+	var wg sync.WaitGroup
+	defer func() {
+		mu.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+		wg.Wait()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		conns[conn] = true
+		mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			srv.serveConn(conn)
+			mu.Lock()
+			delete(conns, conn)
+			mu.Unlock()
+		}()
+	}
+}
+
+// serveConn runs a session on conn. Evaluations in progress stop when the
+// session times out or the connection closes.
+func (srv *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if srv.SessionTimeout > 0 {
+		timer := time.AfterFunc(srv.SessionTimeout, func() {
+			cancel()
+			io.WriteString(conn, "\nsession time limit reached\n")
+			conn.Close()
+		})
+		defer timer.Stop()
+	}
+
+	session := NewSession(&cancelingWriter{w: conn, cancel: cancel})
+	session.Prompts = true
+	session.MaxSteps = limit(srv.MaxSteps, DEFAULT_SESSION_MAX_STEPS)
+	session.Memory = &evaluator.Memory{Limit: limit(srv.MaxMemory, DEFAULT_SESSION_MAX_MEMORY)}
+	if srv.Commands != nil {
+		session.Commands = srv.Commands()
+	} else {
+		session.Commands.Unregister(":load")
+	}
+	io.WriteString(conn, SERVER_BANNER)
+
+	var in io.Reader = conn
+	if srv.IdleTimeout > 0 {
+		in = &idleReader{conn: conn, timeout: srv.IdleTimeout}
+	}
+	session.RunContext(ctx, in)
+}
+
+// limit returns the effective value of a limit that is def when zero and
+// none when negative.
+func limit[T int | int64](value, def T) T {
+	switch {
+	case value == 0:
+		return def
+	case value < 0:
+		return 0
+	}
+	return value
+}
+
+// cancelingWriter cancels the session once writing to the client fails,
+// as it does after the client disconnects.
+type cancelingWriter struct {
+	w      io.Writer
+	cancel context.CancelFunc
+}
+
+func (w *cancelingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if err != nil {
+		w.cancel()
+	}
+	return n, err
+}
+
+// idleReader extends the read deadline of conn before every read.
+type idleReader struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	r.conn.SetReadDeadline(time.Now().Add(r.timeout))
+	return r.conn.Read(p)
+}
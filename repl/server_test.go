@@ -0,0 +1,143 @@
+package repl
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, srv *Server, network, address string) net.Listener {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	done := make(chan error)
+	go func() { done <- srv.Serve(l) }()
+	t.Cleanup(func() {
+		l.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve returned error %v", err)
+		}
+	})
+	return l
+}
+
+type testClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dial(t *testing.T, l net.Listener) *testClient {
+	conn, err := net.Dial(l.Addr().Network(), l.Addr().String())
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	c := &testClient{conn: conn, reader: bufio.NewReader(conn)}
+	c.expect(t, SERVER_BANNER+PROMPT)
+	return c
+}
+
+func (c *testClient) expect(t *testing.T, expected string) {
+	t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, len(expected))
+	if _, err := io.ReadFull(c.reader, buf); err != nil {
+		t.Fatalf("expected %q, read %q: %v", expected, buf, err)
+	}
+	if string(buf) != expected {
+		t.Fatalf("expected %q got %q", expected, buf)
+	}
+}
+
+func (c *testClient) send(line string) {
+	io.WriteString(c.conn, line+"\n")
+}
+
+func TestServeIsolatesSessions(t *testing.T) {
+	l := startServer(t, &Server{}, "tcp", "127.0.0.1:0")
+	first := dial(t, l)
+	second := dial(t, l)
+
+	first.send("let a = 1;")
+	first.expect(t, PROMPT)
+	second.send("let a = 2;")
+	second.expect(t, PROMPT)
+
+	first.send("a")
+	first.expect(t, "1\n"+PROMPT)
+	second.send("a * 10")
+	second.expect(t, "20\n"+PROMPT)
+
+	second.send(":reset")
+	second.expect(t, PROMPT)
+	first.send(":env")
+	first.expect(t, "a = 1\n"+PROMPT)
+}
+
+func TestServeUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "m.sock")
+	l := startServer(t, &Server{}, "unix", path)
+	c := dial(t, l)
+	c.send("if (true) {")
+	c.expect(t, CONTINUATION_PROMPT)
+	c.send("5 }")
+	c.expect(t, "5\n"+PROMPT)
+}
+
+func TestServeDisablesLoad(t *testing.T) {
+	l := startServer(t, &Server{}, "tcp", "127.0.0.1:0")
+	c := dial(t, l)
+	c.send(":load /etc/passwd")
+	c.expect(t, "unknown command :load")
+}
+
+func TestServeSessionTimeout(t *testing.T) {
+	l := startServer(t, &Server{SessionTimeout: 100 * time.Millisecond}, "tcp", "127.0.0.1:0")
+	c := dial(t, l)
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	rest, err := io.ReadAll(c.reader)
+	if err != nil {
+		t.Fatalf("Expected the server to close the connection, got %v", err)
+	}
+	if !strings.Contains(string(rest), "session time limit reached") {
+		t.Errorf("Expected a time limit notice, got %q", rest)
+	}
+}
+
+func TestServeIdleTimeout(t *testing.T) {
+	l := startServer(t, &Server{IdleTimeout: 100 * time.Millisecond}, "tcp", "127.0.0.1:0")
+	c := dial(t, l)
+	c.send("1")
+	c.expect(t, "1\n"+PROMPT)
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadAll(c.reader); err != nil {
+		t.Errorf("Expected an idle connection to be closed, got %v", err)
+	}
+}
+
+func TestServeLimits(t *testing.T) {
+	l := startServer(t, &Server{MaxSteps: 1000, MaxMemory: 4096}, "tcp", "127.0.0.1:0")
+	c := dial(t, l)
+	c.send("for (true) {}")
+	c.expect(t, `Error : "step limit of 1000 exceeded"`+"\n"+PROMPT)
+	c.send(`let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(500)`)
+	c.expect(t, `Error : "memory limit of 4096 bytes exceeded"`+"\n"+PROMPT)
+	c.send("1 + 1")
+	c.expect(t, "2\n"+PROMPT)
+}
+
+func TestServeSessionTimeoutStopsEvaluation(t *testing.T) {
+	// Serve only returns, ending the test, once the evaluation has stopped.
+	l := startServer(t, &Server{SessionTimeout: 100 * time.Millisecond, MaxSteps: -1}, "tcp", "127.0.0.1:0")
+	c := dial(t, l)
+	c.send("for (true) {}")
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadAll(c.reader); err != nil {
+		t.Errorf("Expected the server to close the connection, got %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package repl
+
+import "errors"
+
+// Raw mode is only implemented for Linux; elsewhere the REPL falls back to
+// reading plain lines.
+func isTerminal(fd int) bool { return false }
+
+func enableRawMode(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
@@ -1,13 +1,16 @@
 package token
 
+import "sort"
+
 // constants
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
 	// identifiers and literals
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT  = "IDENT"
+	INT    = "INT"
+	STRING = "STRING"
 
 	/// Operators
 	ASSIGN   = "="
@@ -44,6 +47,8 @@ const (
 type Token struct {
 	Type    Type
 	Literal string
+	// Line is the 1-based source line the token starts on.
+	Line int
 }
 
 var keywords = map[string]Type{
@@ -57,6 +62,16 @@ var keywords = map[string]Type{
 	"for":    FOR,
 }
 
+// Keywords returns the reserved words of the language in sorted order.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
 func LookupIdent(ident string) Type {
 	tokenType, ok := keywords[ident]
 	if ok {
@@ -0,0 +1,404 @@
+// Package vm executes bytecode produced by the compiler package.
+package vm
+
+import (
+	"context"
+	"fmt"
+	"interpreter/code"
+	"interpreter/compiler"
+	"interpreter/evaluator"
+	"interpreter/object"
+)
+
+// STACK_SIZE is the initial size of the operand stack, which grows as
+// needed.
+const STACK_SIZE = 64
+
+type frame struct {
+	cl    *object.Closure
+	ip    int
+	scope *object.Scope
+	// base is the stack pointer to restore on return.
+	base int
+}
+
+// VM runs compiled programs within the same limits as an
+// evaluator.Evaluator, failing with the same *object.Error values. It is
+// not safe for concurrent use.
+type VM struct {
+	// MaxSteps caps the number of instructions executed; zero means no
+	// limit.
+	MaxSteps int
+	// MaxDepth caps nested function calls, which tail calls are not;
+	// zero means evaluator.DEFAULT_MAX_DEPTH.
+	MaxDepth int
+
+	ctx         context.Context
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+	main        *object.Closure
+
+	stack  []object.Object
+	sp     int
+	frames []frame
+	steps  int
+}
+
+// New returns a VM for bytecode that stops with an evaluator.ErrCanceled
+// error once ctx is done. ctx is checked when the program starts, on
+// every loop iteration and on every function call.
+func New(ctx context.Context, bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobals(ctx, bytecode, nil)
+}
+
+// NewWithGlobals returns a VM whose global slots start out as globals,
+// which is extended to hold every global of bytecode. Values of globals
+// left by one run are seen by the next one given the same slice, as
+// returned by Globals.
+func NewWithGlobals(ctx context.Context, bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	if missing := len(bytecode.Globals) - len(globals); missing > 0 {
+		globals = append(globals, make([]object.Object, missing)...)
+	}
+	return &VM{
+		ctx:         ctx,
+		constants:   bytecode.Constants,
+		globals:     globals,
+		globalNames: bytecode.Globals,
+		main:        &object.Closure{Fn: &object.CompiledFunction{Instructions: bytecode.Instructions}},
+		stack:       make([]object.Object, STACK_SIZE),
+	}
+}
+
+// Globals returns the global slots, indexed as the compiler numbered them.
+func (vm *VM) Globals() []object.Object {
+	return vm.globals
+}
+
+// Steps returns the number of instructions executed so far.
+func (vm *VM) Steps() int {
+	return vm.steps
+}
+
+// Run runs the program and returns its value, or the *object.Error that
+// ended it. Bytecode the compiler did not produce, such as a corrupt file
+// that passed decoding, ends with an error rather than a panic.
+func (vm *VM) Run() (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = createError("invalid bytecode: %v", r)
+		}
+	}()
+	if err := vm.ctx.Err(); err != nil {
+		return evaluator.CanceledError(err)
+	}
+	maxDepth := vm.maxDepth()
+
+	vm.sp = 0
+	vm.frames = append(vm.frames[:0], frame{cl: vm.main})
+	f := &vm.frames[0]
+	ins := f.cl.Fn.Instructions
+	ip := 0
+
+	for {
+		vm.steps++
+		if vm.MaxSteps > 0 && vm.steps > vm.MaxSteps {
+			return evaluator.StepLimitError(vm.MaxSteps)
+		}
+		op := code.Opcode(ins[ip])
+		ip++
+
+		switch op {
+		case code.OpConstant:
+			vm.push(vm.constants[code.ReadUint16(ins[ip:])])
+			ip += 2
+
+		case code.OpPop:
+			vm.sp--
+
+		case code.OpTrue:
+			vm.push(object.TRUE)
+		case code.OpFalse:
+			vm.push(object.FALSE)
+		case code.OpNull:
+			vm.push(object.NULL)
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan:
+			right := vm.stack[vm.sp-1]
+			left := vm.stack[vm.sp-2]
+			vm.sp -= 2
+			result := binaryOperation(op, left, right)
+			if isError(result) {
+				return result
+			}
+			vm.push(result)
+
+		case code.OpMinus:
+			right := vm.stack[vm.sp-1]
+			if integer, ok := right.(*object.Integer); ok {
+				vm.stack[vm.sp-1] = object.NewInteger(-integer.Value)
+				break
+			}
+			result := evaluator.EvalPrefix("-", right)
+			if isError(result) {
+				return result
+			}
+			vm.stack[vm.sp-1] = result
+		case code.OpBang:
+			vm.stack[vm.sp-1] = object.NativeBool(!evaluator.IsTruthy(vm.stack[vm.sp-1]))
+
+		case code.OpJump:
+			target := int(code.ReadUint16(ins[ip:]))
+			if target < ip {
+				if err := vm.ctx.Err(); err != nil {
+					return evaluator.CanceledError(err)
+				}
+			}
+			ip = target
+		case code.OpJumpNotTruthy:
+			vm.sp--
+			if evaluator.IsTruthy(vm.stack[vm.sp]) {
+				ip += 2
+			} else {
+				ip = int(code.ReadUint16(ins[ip:]))
+			}
+
+		case code.OpGetGlobal:
+			index := code.ReadUint16(ins[ip:])
+			ip += 2
+			value := vm.globals[index]
+			if value == nil {
+				return notFoundError(vm.globalNames[index])
+			}
+			vm.push(value)
+		case code.OpSetGlobal:
+			vm.sp--
+			vm.globals[code.ReadUint16(ins[ip:])] = vm.stack[vm.sp]
+			ip += 2
+		case code.OpAssignGlobal:
+			index := code.ReadUint16(ins[ip:])
+			ip += 2
+			if vm.globals[index] == nil {
+				return notFoundError(vm.globalNames[index])
+			}
+			vm.sp--
+			vm.globals[index] = vm.stack[vm.sp]
+
+		case code.OpGetLocal:
+			index := ins[ip]
+			ip++
+			value := f.scope.Slots[index]
+			if value == nil {
+				return notFoundError(f.scope.Names[index])
+			}
+			vm.push(value)
+		case code.OpSetLocal:
+			vm.sp--
+			f.scope.Slots[ins[ip]] = vm.stack[vm.sp]
+			ip++
+		case code.OpGetOuter:
+			scope, index := f.scope.Outer, int(ins[ip+1])
+			for depth := ins[ip]; depth > 1 && scope != nil; depth-- {
+				scope = scope.Outer
+			}
+			ip += 2
+			if scope == nil || index >= len(scope.Slots) {
+				return createError("invalid outer variable %d", index)
+			}
+			value := scope.Slots[index]
+			if value == nil {
+				return notFoundError(scope.Names[index])
+			}
+			vm.push(value)
+		case code.OpSetOuter:
+			scope, index := f.scope.Outer, int(ins[ip+1])
+			for depth := ins[ip]; depth > 1 && scope != nil; depth-- {
+				scope = scope.Outer
+			}
+			ip += 2
+			if scope == nil || index >= len(scope.Slots) {
+				return createError("invalid outer variable %d", index)
+			}
+			vm.sp--
+			scope.Slots[index] = vm.stack[vm.sp]
+
+		case code.OpClosure:
+			fn := vm.constants[code.ReadUint16(ins[ip:])].(*object.CompiledFunction)
+			ip += 2
+			vm.push(&object.Closure{Fn: fn, Env: f.scope})
+
+		case code.OpCall:
+			numArgs := int(ins[ip])
+			ip++
+			if err := vm.ctx.Err(); err != nil {
+				return evaluator.CanceledError(err)
+			}
+			callee := vm.stack[vm.sp-1-numArgs]
+			cl, ok := callee.(*object.Closure)
+			if !ok {
+				result := vm.callObject(callee, numArgs)
+				if isError(result) {
+					return result
+				}
+				vm.push(result)
+				break
+			}
+			if numArgs != cl.Fn.NumParameters {
+				return createError("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+			}
+			// a call whose value the function returns replaces its frame
+			tail := len(vm.frames) > 1 && returnsAt(ins, ip)
+			if !tail && len(vm.frames) > maxDepth {
+				return evaluator.DepthLimitError(maxDepth)
+			}
+			scope := &object.Scope{
+				Slots: make([]object.Object, cl.Fn.NumLocals),
+				Names: cl.Fn.Locals,
+				Outer: cl.Env,
+			}
+			copy(scope.Slots, vm.stack[vm.sp-numArgs:vm.sp])
+			if tail {
+				vm.sp = f.base
+				*f = frame{cl: cl, scope: scope, base: f.base}
+			} else {
+				f.ip = ip
+				vm.sp -= numArgs + 1
+				vm.frames = append(vm.frames, frame{cl: cl, scope: scope, base: vm.sp})
+				f = &vm.frames[len(vm.frames)-1]
+			}
+			ins = cl.Fn.Instructions
+			ip = 0
+
+		case code.OpReturnValue, code.OpReturn:
+			result := object.Object(object.NULL)
+			if op == code.OpReturnValue {
+				vm.sp--
+				result = vm.stack[vm.sp]
+			}
+			if len(vm.frames) == 1 {
+				return result
+			}
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.sp = f.base
+			f = &vm.frames[len(vm.frames)-1]
+			ins = f.cl.Fn.Instructions
+			ip = f.ip
+			vm.push(result)
+
+		default:
+			return createError("unknown opcode %d", op)
+		}
+	}
+}
+
+// returnsAt reports whether the instruction at ip returns its operand,
+// following forward jumps such as the one ending an if branch.
+func returnsAt(ins code.Instructions, ip int) bool {
+	for ip < len(ins) {
+		switch code.Opcode(ins[ip]) {
+		case code.OpReturnValue:
+			return true
+		case code.OpJump:
+			target := int(code.ReadUint16(ins[ip+1:]))
+			if target <= ip {
+				return false
+			}
+			ip = target
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// callObject calls a callee other than a closure with the numArgs values
+// above it on the stack, removing them and the callee.
+func (vm *VM) callObject(callee object.Object, numArgs int) object.Object {
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+	vm.sp -= numArgs + 1
+	switch callee := callee.(type) {
+	case *object.Builtin:
+		return callee.Fn(args...)
+	case *object.Function:
+		return vm.applyFunction(callee, args)
+	}
+	return createError("not a function: %s", callee.Type())
+}
+
+// applyFunction calls a function of the evaluator within what is left of
+// the VM's step and depth limits, counting the steps it takes as the VM's.
+func (vm *VM) applyFunction(fn *object.Function, args []object.Object) object.Object {
+	result, steps := evaluator.ApplyWithin(vm.ctx, fn, args, vm.MaxSteps, vm.steps, vm.maxDepth(), len(vm.frames)-1)
+	vm.steps = steps
+	return result
+}
+
+func (vm *VM) maxDepth() int {
+	if vm.MaxDepth > 0 {
+		return vm.MaxDepth
+	}
+	return evaluator.DEFAULT_MAX_DEPTH
+}
+
+func (vm *VM) push(obj object.Object) {
+	if vm.sp == len(vm.stack) {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+	vm.stack[vm.sp] = obj
+	vm.sp++
+}
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+	code.OpLessThan:    "<",
+}
+
+// binaryOperation computes integer operations directly and leaves the
+// rest, errors included, to the evaluator.
+func binaryOperation(op code.Opcode, left, right object.Object) object.Object {
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			switch op {
+			case code.OpAdd:
+				return object.NewInteger(l.Value + r.Value)
+			case code.OpSub:
+				return object.NewInteger(l.Value - r.Value)
+			case code.OpMul:
+				return object.NewInteger(l.Value * r.Value)
+			case code.OpDiv:
+				if r.Value != 0 {
+					return object.NewInteger(l.Value / r.Value)
+				}
+			case code.OpEqual:
+				return object.NativeBool(l.Value == r.Value)
+			case code.OpNotEqual:
+				return object.NativeBool(l.Value != r.Value)
+			case code.OpGreaterThan:
+				return object.NativeBool(l.Value > r.Value)
+			case code.OpLessThan:
+				return object.NativeBool(l.Value < r.Value)
+			}
+		}
+	}
+	return evaluator.EvalInfix(left, infixOperators[op], right)
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
+
+func createError(format string, args ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, args...)}
+}
+
+func notFoundError(name string) *object.Error {
+	return createError("identifier not found: %s", name)
+}
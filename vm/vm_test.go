@@ -0,0 +1,190 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"interpreter/compiler"
+	"interpreter/evaluator"
+	"interpreter/evaluator/evaltest"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"testing"
+	"time"
+)
+
+func runVM(t testing.TB, input string, configure func(*VM)) object.Object {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("%s: parse errors %v", input, p.Errors())
+	}
+	symbols := compiler.NewSymbolTable()
+	var globals []object.Object
+	for name, builtin := range evaltest.Builtins {
+		symbols.Define(name)
+		globals = append(globals, builtin)
+	}
+	c := compiler.NewWithState(symbols, nil)
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("%s: %v", input, err)
+	}
+	vm := NewWithGlobals(context.Background(), c.Bytecode(), globals)
+	if configure != nil {
+		configure(vm)
+	}
+	return vm.Run()
+}
+
+func TestParity(t *testing.T) {
+	for _, input := range evaltest.Corpus {
+		want := evaltest.Eval(input)
+		got := runVM(t, input, nil)
+		if got.Type() != want.Type() || got.Inspect() != want.Inspect() {
+			t.Errorf("%s: vm = %s, evaluator = %s", input, got.Inspect(), want.Inspect())
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-expired.Done()
+
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		maxSteps int
+		maxDepth int
+		expected error
+		message  string
+	}{
+		{"for (true) {}", expired, 0, 0, context.DeadlineExceeded, "evaluation canceled: context deadline exceeded"},
+		{"for (true) {}", context.Background(), 1000, 0, evaluator.ErrStepLimit, "step limit of 1000 exceeded"},
+		{"let f = fn(n) { 1 + f(n + 1) }; f(0)", context.Background(), 0, 50, evaluator.ErrDepthLimit, "maximum recursion depth of 50 exceeded"},
+		{"let f = fn(n) { 1 + f(n + 1) }; f(0)", context.Background(), 0, 0, evaluator.ErrDepthLimit, "maximum recursion depth of 10000 exceeded"},
+	}
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		c := compiler.New()
+		if err := c.Compile(p.ParseProgram()); err != nil {
+			t.Fatal(err)
+		}
+		vm := New(tt.ctx, c.Bytecode())
+		vm.MaxSteps = tt.maxSteps
+		vm.MaxDepth = tt.maxDepth
+		errorObj, ok := vm.Run().(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if !errors.Is(errorObj.Err, tt.expected) || errorObj.Message != tt.message {
+			t.Errorf("%s: got %q (%v), want %q (%v)", tt.input, errorObj.Message, errorObj.Err, tt.message, tt.expected)
+		}
+	}
+}
+
+func TestEvaluatorFunctionLimits(t *testing.T) {
+	env := object.NewEnvironment()
+	evaluator.Eval(parser.New(lexer.New(
+		"let spin = fn() { for (true) {} }; let deep = fn(n) { if (n == 0) { 0 } else { 1 + deep(n - 1) } };",
+	)).ParseProgram(), env)
+
+	tests := []struct {
+		input    string
+		maxSteps int
+		maxDepth int
+		expected error
+		message  string
+	}{
+		{"spin()", 1000, 0, evaluator.ErrStepLimit, "step limit of 1000 exceeded"},
+		{"deep(100)", 0, 50, evaluator.ErrDepthLimit, "maximum recursion depth of 50 exceeded"},
+		{"let f = fn(n) { if (n == 0) { deep(40) } else { 0 + f(n - 1) } }; f(20)", 0, 50, evaluator.ErrDepthLimit, "maximum recursion depth of 50 exceeded"},
+		{"deep(40)", 0, 50, nil, ""},
+	}
+	for _, tt := range tests {
+		symbols := compiler.NewSymbolTable()
+		var globals []object.Object
+		for _, name := range []string{"spin", "deep"} {
+			fn, _ := env.Get(name)
+			symbols.Define(name)
+			globals = append(globals, fn)
+		}
+		c := compiler.NewWithState(symbols, nil)
+		if err := c.Compile(parser.New(lexer.New(tt.input)).ParseProgram()); err != nil {
+			t.Fatal(err)
+		}
+		vm := NewWithGlobals(context.Background(), c.Bytecode(), globals)
+		vm.MaxSteps = tt.maxSteps
+		vm.MaxDepth = tt.maxDepth
+		result := vm.Run()
+		errorObj, ok := result.(*object.Error)
+		if tt.expected == nil {
+			if ok {
+				t.Errorf("%s: unexpected error %q", tt.input, errorObj.Message)
+			}
+			continue
+		}
+		if !ok || !errors.Is(errorObj.Err, tt.expected) || errorObj.Message != tt.message {
+			t.Errorf("%s: got %s, want %q (%v)", tt.input, result.Inspect(), tt.message, tt.expected)
+		}
+	}
+}
+
+func TestDeepRecursionGrowsStack(t *testing.T) {
+	result := runVM(t, "let sum = fn(n) { if (n == 0) { return 0; } n + sum(n - 1) }; sum(9000)", nil)
+	if integer, ok := result.(*object.Integer); !ok || integer.Value != 9000*9001/2 {
+		t.Errorf("sum = %s", result.Inspect())
+	}
+}
+
+func TestGlobalsPersist(t *testing.T) {
+	symbols := compiler.NewSymbolTable()
+	var constants, globals []object.Object
+	for _, input := range []string{"let a = 40;", "let f = fn() { a + b };", "let b = 2;", "f()"} {
+		c := compiler.NewWithState(symbols, constants)
+		if err := c.Compile(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+			t.Fatal(err)
+		}
+		bytecode := c.Bytecode()
+		constants = bytecode.Constants
+		vm := NewWithGlobals(context.Background(), bytecode, globals)
+		result := vm.Run()
+		globals = vm.Globals()
+		if input == "f()" && result.Inspect() != "42" {
+			t.Errorf("f() = %s, want 42", result.Inspect())
+		}
+	}
+}
+
+const fibScript = "let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(20)"
+
+const loopScript = "let i = 0; let s = 0; for (i < 100000) { s = s + i * 2; i = i + 1; } s"
+
+func BenchmarkFib(b *testing.B) {
+	benchmarkBackends(b, fibScript)
+}
+
+func BenchmarkLoop(b *testing.B) {
+	benchmarkBackends(b, loopScript)
+}
+
+func benchmarkBackends(b *testing.B, script string) {
+	b.Run("evaluator", func(b *testing.B) {
+		program := parser.New(lexer.New(script)).ParseProgram()
+		for i := 0; i < b.N; i++ {
+			evaluator.Eval(program, object.NewEnvironment())
+		}
+	})
+	b.Run("vm", func(b *testing.B) {
+		c := compiler.New()
+		if err := c.Compile(parser.New(lexer.New(script)).ParseProgram()); err != nil {
+			b.Fatal(err)
+		}
+		bytecode := c.Bytecode()
+		for i := 0; i < b.N; i++ {
+			New(context.Background(), bytecode).Run()
+		}
+	})
+}